@@ -0,0 +1,128 @@
+package analyzer
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/Lslightly/pprof2csv/symbolizer"
+)
+
+// AnalyzeAddresses parses the pprof profile data and aggregates the sample type selected
+// by opts per instruction address, i.e. per location. Flat is the value of the samples
+// whose innermost location is at the address.
+// The result is sorted by flat descending, then by cum descending.
+func AnalyzeAddresses(data []byte, opts Options) ([]*models.AddressStat, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	valueIdx, err := selectSampleIndex(p, opts.SampleIndex)
+	if err != nil {
+		return nil, err
+	}
+	scale, unit, err := valueScale(p.SampleType[valueIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	addrMap := make(map[uint64]*models.AddressStat) // keyed by location ID
+	seen := make(map[uint64]bool)
+	for _, sample := range p.Sample {
+		if len(sample.Value) <= valueIdx {
+			continue
+		}
+		delta := models.Value{Amount: sample.Value[valueIdx] * scale, Unit: unit}
+
+		clear(seen)
+		for i, loc := range sample.Location {
+			as, exists := addrMap[loc.ID]
+			if !exists {
+				as = &models.AddressStat{
+					Address: loc.Address,
+					Offset:  loc.Address,
+					Cum:     models.Value{Unit: unit},
+					Flat:    models.Value{Unit: unit},
+				}
+				if m := loc.Mapping; m != nil {
+					as.MappingFile = m.File
+					as.Offset = loc.Address - m.Start + m.Offset
+				}
+				if len(loc.Line) > 0 && loc.Line[0].Function != nil {
+					as.FunctionName = loc.Line[0].Function.Name
+					as.Filename = loc.Line[0].Function.Filename
+					as.LineNumber = int(loc.Line[0].Line)
+				}
+				addrMap[loc.ID] = as
+			}
+			if i == 0 {
+				as.Flat = as.Flat.Add(delta)
+			}
+			// Recursive frames of the same address count once, see Options.PerFrameCum
+			if opts.PerFrameCum || !seen[loc.ID] {
+				as.Cum = as.Cum.Add(delta)
+			}
+			seen[loc.ID] = true
+		}
+	}
+
+	result := make([]*models.AddressStat, 0, len(addrMap))
+	for _, as := range addrMap {
+		result = append(result, as)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Flat.Amount != result[j].Flat.Amount {
+			return result[i].Flat.Amount > result[j].Flat.Amount
+		}
+		if result[i].Cum.Amount != result[j].Cum.Amount {
+			return result[i].Cum.Amount > result[j].Cum.Amount
+		}
+		return result[i].Address < result[j].Address
+	})
+
+	return result, nil
+}
+
+// AddDisassembly sets the disassembly of the address stats of the binary at path,
+// disassembled with go tool objdump. Like go tool pprof -disasm, an address gets the
+// instruction containing it. Addresses of other mappings, e.g. shared libraries, and
+// of functions the binary does not contain are left without disassembly.
+func AddDisassembly(stats []*models.AddressStat, binary string) error {
+	b, err := symbolizer.Open(binary)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	// Only the functions containing the addresses are disassembled. They are looked up in
+	// the binary, as the function of a stat may be inlined into another one. A return
+	// address may also be the first address after its function
+	var functions []string
+	for _, as := range stats {
+		if !b.OwnsFile(as.MappingFile) {
+			continue
+		}
+		addr := b.OffsetAddr(as.Offset)
+		for _, a := range []uint64{addr, addr - 1} {
+			if frame, ok := b.Lookup(a); ok && !slices.Contains(functions, frame.Function) {
+				functions = append(functions, frame.Function)
+			}
+		}
+	}
+	insts, err := b.Disassemble(functions)
+	if err != nil {
+		return err
+	}
+
+	for _, as := range stats {
+		if !b.OwnsFile(as.MappingFile) {
+			continue
+		}
+		if inst, ok := symbolizer.FindInstruction(insts, b.OffsetAddr(as.Offset)); ok {
+			as.Disassembly = inst.Text
+		}
+	}
+	return nil
+}
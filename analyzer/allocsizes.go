@@ -0,0 +1,107 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Lslightly/pprof2csv/models"
+)
+
+// sizeClasses are the object sizes of the Go allocator's small size classes,
+// see internal/runtime/gc/sizeclasses.go.
+var sizeClasses = []int64{0, 8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224, 240, 256, 288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768, 896, 1024, 1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200, 3456, 4096, 4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240, 10880, 12288, 13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576, 27264, 28672, 32768}
+
+// sizeClass returns the upper bound of the bucket of an object of size bytes:
+// the smallest Go size class that fits the object, or the next power of two for
+// large objects (> 32KiB).
+func sizeClass(size int64) int64 {
+	i := sort.Search(len(sizeClasses), func(i int) bool { return sizeClasses[i] >= size })
+	if i < len(sizeClasses) {
+		return sizeClasses[i]
+	}
+	class := sizeClasses[len(sizeClasses)-1]
+	for class < size {
+		class *= 2
+	}
+	return class
+}
+
+// AnalyzeAllocSizes parses the heap profile data and builds a histogram of object
+// sizes for each allocating source line, i.e. the innermost line of each sample.
+// The object size is taken from the "bytes" numeric label of Go heap profile samples.
+// opts.SampleIndex selects between alloc_* (default) and inuse_* values: the objects
+// and bytes of a bucket are taken from <prefix>_objects and <prefix>_space.
+// The result is sorted by bytes descending.
+func AnalyzeAllocSizes(data []byte, opts Options) ([]*models.AllocSizeBucket, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	valueIdx, err := selectSampleIndex(p, opts.SampleIndex)
+	if err != nil {
+		return nil, err
+	}
+	prefix := "alloc"
+	if strings.HasPrefix(p.SampleType[valueIdx].Type, "inuse_") {
+		prefix = "inuse"
+	}
+	objectsIdx, err := p.SampleIndexByName(prefix + "_objects")
+	if err != nil {
+		return nil, fmt.Errorf("not a heap profile: %w", err)
+	}
+	spaceIdx, err := p.SampleIndexByName(prefix + "_space")
+	if err != nil {
+		return nil, fmt.Errorf("not a heap profile: %w", err)
+	}
+	spaceScale, spaceUnit, err := valueScale(p.SampleType[spaceIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	bucketMap := make(map[string]*models.AllocSizeBucket)
+	for _, sample := range p.Sample {
+		sizes := sample.NumLabel["bytes"]
+		if len(sizes) == 0 || len(sample.Location) == 0 || len(sample.Location[0].Line) == 0 {
+			continue
+		}
+		line := sample.Location[0].Line[0]
+		if line.Function == nil || line.Function.Filename == "" || line.Function.Name == "" {
+			continue
+		}
+
+		if len(sample.Value) <= max(objectsIdx, spaceIdx) {
+			return nil, fmt.Errorf("sample has %d values, want %s_objects and %s_space", len(sample.Value), prefix, prefix)
+		}
+
+		class := sizeClass(sizes[0])
+		key := fmt.Sprintf("%s:%d:%s:%d", line.Function.Filename, line.Line, line.Function.Name, class)
+		objects := models.Value{Amount: sample.Value[objectsIdx], Unit: models.UnitCount}
+		space := models.Value{Amount: sample.Value[spaceIdx] * spaceScale, Unit: spaceUnit}
+		if b, exists := bucketMap[key]; exists {
+			b.Objects = b.Objects.Add(objects)
+			b.Bytes = b.Bytes.Add(space)
+		} else {
+			bucketMap[key] = &models.AllocSizeBucket{
+				Filename:     line.Function.Filename,
+				LineNumber:   int(line.Line),
+				FunctionName: line.Function.Name,
+				SizeClass:    class,
+				Objects:      objects,
+				Bytes:        space,
+			}
+		}
+	}
+
+	result := make([]*models.AllocSizeBucket, 0, len(bucketMap))
+	for _, b := range bucketMap {
+		result = append(result, b)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Bytes.Amount > result[j].Bytes.Amount
+	})
+
+	return result, nil
+}
@@ -1,23 +1,117 @@
 package analyzer
 
 import (
+	"bytes"
 	"fmt"
-	"os"
 	"sort"
 	"time"
 
+	"github.com/Lslightly/pprof2csv/loader"
 	"github.com/Lslightly/pprof2csv/models"
+	"github.com/Lslightly/pprof2csv/srcpath"
+	"github.com/Lslightly/pprof2csv/symbolizer"
 	"github.com/google/pprof/profile"
 )
 
-func convTimeUnit(s string) time.Duration {
-	switch s {
-	case "nanoseconds":
-		return time.Nanosecond
-	default:
-		fmt.Printf("unknown time unit %s\n", s)
-		return time.Nanosecond
+// Options controls which samples and values of a profile are analyzed.
+type Options struct {
+	// ShowFrom, if non-empty, only includes samples whose stacktrace contains this function.
+	// It is an alias of Filter.Focus set to ShowFromFocus(ShowFrom), and cannot be
+	// combined with Filter.Focus.
+	ShowFrom string
+	// Filter selects the analyzed samples and frames by regular expressions and labels,
+	// like the -focus, -ignore, -hide, -show, -prune_from, -tagfocus and -tagignore
+	// options of go tool pprof.
+	Filter Filter
+	// SampleIndex selects the sample type by name (e.g. "cpu", "alloc_space", "delay")
+	// or by index, like go tool pprof -sample_index. Empty selects the profile's
+	// DefaultSampleType, or the last sample type if it is not set.
+	SampleIndex string
+	// Binary, if non-empty, is the path of the binary the profile was collected from.
+	// Locations without line information are symbolized against it, see symbolizer.
+	Binary string
+	// Paths normalizes the source file paths of the profile. The zero value rewrites
+	// the paths of the Go distribution and the module cache, see srcpath.Normalizer.
+	Paths srcpath.Normalizer
+	// PerFrameCum adds the value of a sample to the cum of a line or function once for
+	// every frame it appears in, so that cum of recursive functions can exceed the total.
+	// By default, a sample counts at most once per line and once per function.
+	PerFrameCum bool
+}
+
+// UnsymbolizedFunction is the function name of the pseudo line and function that
+// hold the samples whose innermost location has no line information.
+const UnsymbolizedFunction = "[unsymbolized]"
+
+// parseProfile parses the profile data, symbolizes it against opts.Binary,
+// normalizes its source file paths with opts.Paths and filters its samples by
+// opts.ShowFrom and opts.Filter. Filters see the normalized paths.
+func parseProfile(data []byte, opts Options) (*profile.Profile, error) {
+	p, err := profile.ParseData(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile data: %w", err)
+	}
+	if opts.Binary != "" {
+		if _, err := symbolizer.SymbolizeFile(p, opts.Binary); err != nil {
+			return nil, err
+		}
+	}
+	files := make([]srcpath.File, len(p.Function))
+	for i, fn := range p.Function {
+		files[i] = srcpath.File{Filename: fn.Filename, Function: fn.Name}
+	}
+	for i, filename := range opts.Paths.NormalizeAll(files) {
+		p.Function[i].Filename = filename
+	}
+	filter := opts.Filter
+	if opts.ShowFrom != "" {
+		if filter.Focus != "" {
+			return nil, fmt.Errorf("show_from %s cannot be combined with focus %s", opts.ShowFrom, filter.Focus)
+		}
+		filter.Focus = ShowFromFocus(opts.ShowFrom)
+	}
+	if err := filter.apply(p); err != nil {
+		return nil, err
 	}
+	return p, nil
+}
+
+// Symbolize symbolizes the profile data against binary, so that it can be analyzed
+// several times, e.g. for percentage totals, without Options.Binary. It returns the
+// symbolized profile, the number of samples whose innermost location still has no
+// line information and the number of samples.
+func Symbolize(data []byte, binary string) (out []byte, unsymbolized, total int, err error) {
+	p, err := profile.ParseData(data)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to parse profile data: %w", err)
+	}
+	if _, err := symbolizer.SymbolizeFile(p, binary); err != nil {
+		return nil, 0, 0, err
+	}
+	for _, sample := range p.Sample {
+		if !symbolized(sample) {
+			unsymbolized++
+		}
+	}
+	var buf bytes.Buffer
+	if err := p.WriteUncompressed(&buf); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to write symbolized profile: %w", err)
+	}
+	return buf.Bytes(), unsymbolized, len(p.Sample), nil
+}
+
+// symbolized reports whether the innermost location of sample has line information.
+func symbolized(sample *profile.Sample) bool {
+	return len(sample.Location) > 0 && len(sample.Location[0].Line) > 0
+}
+
+// selectSampleIndex resolves sampleIndex to an index into p.SampleType.
+// The error lists the available sample types if sampleIndex is unknown.
+func selectSampleIndex(p *profile.Profile, sampleIndex string) (int, error) {
+	if len(p.SampleType) == 0 {
+		return 0, fmt.Errorf("profile has no sample types")
+	}
+	return p.SampleIndexByName(sampleIndex)
 }
 
 // AnalyzeWithFunctionStats parses the pprof profile data and extracts both
@@ -25,46 +119,65 @@ func convTimeUnit(s string) time.Duration {
 // (flat: self time, cum: self + callees).
 // If showFrom is non-empty, only samples whose stacktrace contains the specified
 // function are included in the analysis.
+// The default sample type of the profile is analyzed, see Options.SampleIndex.
 // It returns:
 //   - lines: per-source-line stats sorted by cumulative time descending
 //   - funcStats: map keyed by function name with flat/cum times.
 func AnalyzeWithFunctionStats(data []byte, showFrom string) ([]*models.SourceLine, map[string]*models.FunctionStat, error) {
-	p, err := profile.ParseData(data)
+	return AnalyzeWithOptions(data, Options{ShowFrom: showFrom})
+}
+
+// AnalyzeWithOptions is like AnalyzeWithFunctionStats, but the samples and the
+// analyzed sample type are selected by opts.
+func AnalyzeWithOptions(data []byte, opts Options) ([]*models.SourceLine, map[string]*models.FunctionStat, error) {
+	p, err := parseProfile(data, opts)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse profile data: %w", err)
+		return nil, nil, err
 	}
+	return analyzeProfile(p, opts)
+}
 
-	timeUnit := convTimeUnit(p.SampleType[1].Unit)
+// analyzeProfile aggregates the sample type selected by opts of the parsed profile p
+// per source line and per function, see AnalyzeWithFunctionStats.
+func analyzeProfile(p *profile.Profile, opts Options) ([]*models.SourceLine, map[string]*models.FunctionStat, error) {
+	valueIdx, err := selectSampleIndex(p, opts.SampleIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	scale, unit, err := valueScale(p.SampleType[valueIdx])
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Create maps to aggregate time by source line and by function
 	lineMap := make(map[string]*models.SourceLine)
 	funcMap := make(map[string]*models.FunctionStat)
+	// Value of the samples whose innermost location has no line information
+	var unsymbolized *models.Value
+	// Functions each line was inlined into
+	inlinedInto := make(map[string]map[string]bool)
+	// Lines and functions whose cum already includes the current sample
+	seenLines := make(map[string]bool)
+	seenFuncs := make(map[string]bool)
 
 	// Process each sample in the profile
 	for _, sample := range p.Sample {
-		// Filter: skip sample if showFrom specified but not found in stacktrace
-		if showFrom != "" {
-			found := false
-		locationLoop:
-			for _, loc := range sample.Location {
-				for _, le := range loc.Line {
-					if le.Function != nil && le.Function.Name == showFrom {
-						found = true
-						break locationLoop
-					}
-				}
-			}
-			if !found {
-				continue
-			}
+		// Get the value (time, bytes or count) for this sample
+		var value int64
+		if len(sample.Value) > valueIdx {
+			value = sample.Value[valueIdx]
 		}
 
-		// Get the value (time) for this sample
-		var value int64
-		if len(sample.Value) > 0 {
-			value = sample.Value[1]
+		if !symbolized(sample) {
+			if unsymbolized == nil {
+				unsymbolized = &models.Value{Unit: unit}
+			}
+			*unsymbolized = unsymbolized.Add(models.Value{Amount: value * scale, Unit: unit})
 		}
 
+		clear(seenLines)
+		clear(seenFuncs)
+
 		// Process each location in the stack trace
 		for i, loc := range sample.Location {
 			// Skip locations without lines
@@ -72,8 +185,9 @@ func AnalyzeWithFunctionStats(data []byte, showFrom string) ([]*models.SourceLin
 				continue
 			}
 
-			// Process all lines in the location, as a location may map to multiple source lines
-			for _, lineEntry := range loc.Line {
+			// Process all lines in the location, as a location may map to multiple source lines.
+			// Line[j] was inlined into Line[j+1], Line[0] is the innermost one
+			for j, lineEntry := range loc.Line {
 				line := lineEntry
 
 				// Skip if no filename or function name
@@ -85,38 +199,59 @@ func AnalyzeWithFunctionStats(data []byte, showFrom string) ([]*models.SourceLin
 				key := fmt.Sprintf("%s:%d:%s", line.Function.Filename, line.Line, line.Function.Name)
 
 				// Flat time is the time spent directly in this function (leaf node in call stack)
-				// Only the innermost function call (i == 0) gets the full sample value as flat time
+				// Only the innermost line of the innermost location gets the sample value as flat time,
+				// the lines it was inlined into are callers
 				flatTime := int64(0)
-				if i == 0 {
+				if i == 0 && j == 0 {
 					flatTime = value
 				}
 
-				cumDelta := time.Duration(value) * timeUnit
-				flatDelta := time.Duration(flatTime) * timeUnit
+				if j+1 < len(loc.Line) && loc.Line[j+1].Function != nil {
+					if inlinedInto[key] == nil {
+						inlinedInto[key] = make(map[string]bool)
+					}
+					inlinedInto[key][loc.Line[j+1].Function.Name] = true
+				}
+
+				cumDelta := models.Value{Amount: value * scale, Unit: unit}
+				flatDelta := models.Value{Amount: flatTime * scale, Unit: unit}
+				fn := line.Function.Name
+
+				// Recursive frames of the same line or function count once
+				lineCumDelta, funcCumDelta := cumDelta, cumDelta
+				if !opts.PerFrameCum {
+					if seenLines[key] {
+						lineCumDelta.Amount = 0
+					}
+					if seenFuncs[fn] {
+						funcCumDelta.Amount = 0
+					}
+					seenLines[key] = true
+					seenFuncs[fn] = true
+				}
 
 				// Update or create entry for this source line
 				if sl, exists := lineMap[key]; exists {
-					sl.Cum += cumDelta
-					sl.Flat += flatDelta
+					sl.Cum = sl.Cum.Add(lineCumDelta)
+					sl.Flat = sl.Flat.Add(flatDelta)
 				} else {
 					lineMap[key] = &models.SourceLine{
 						Filename:     line.Function.Filename,
 						LineNumber:   int(line.Line),
 						FunctionName: line.Function.Name,
-						Cum:          cumDelta,
+						Cum:          lineCumDelta,
 						Flat:         flatDelta,
 					}
 				}
 
 				// Update or create entry for this function (function-level stats)
-				fn := line.Function.Name
 				if fs, exists := funcMap[fn]; exists {
-					fs.Cum += cumDelta
-					fs.Flat += flatDelta
+					fs.Cum = fs.Cum.Add(funcCumDelta)
+					fs.Flat = fs.Flat.Add(flatDelta)
 				} else {
 					funcMap[fn] = &models.FunctionStat{
 						FunctionName: fn,
-						Cum:          cumDelta,
+						Cum:          funcCumDelta,
 						Flat:         flatDelta,
 					}
 				}
@@ -124,15 +259,25 @@ func AnalyzeWithFunctionStats(data []byte, showFrom string) ([]*models.SourceLin
 		}
 	}
 
+	// Unsymbolized samples are reported as a pseudo line and function, see Options.Binary
+	if unsymbolized != nil {
+		lineMap[UnsymbolizedFunction] = &models.SourceLine{FunctionName: UnsymbolizedFunction, Cum: *unsymbolized, Flat: *unsymbolized}
+		funcMap[UnsymbolizedFunction] = &models.FunctionStat{FunctionName: UnsymbolizedFunction, Cum: *unsymbolized, Flat: *unsymbolized}
+	}
+
 	// Convert map to sorted slice for line-level stats
 	result := make([]*models.SourceLine, 0, len(lineMap))
-	for _, line := range lineMap {
+	for key, line := range lineMap {
+		for fn := range inlinedInto[key] {
+			line.InlinedInto = append(line.InlinedInto, fn)
+		}
+		sort.Strings(line.InlinedInto)
 		result = append(result, line)
 	}
 
 	// Sort by cumulative time (descending)
 	sort.Slice(result, func(i, j int) bool {
-		return result[i].Cum > result[j].Cum
+		return result[i].Cum.Amount > result[j].Cum.Amount
 	})
 
 	return result, funcMap, nil
@@ -148,18 +293,32 @@ func Analyze(data []byte, showFrom string) ([]*models.SourceLine, error) {
 }
 
 // LoadProfileDataWithFunctionStats loads profile data from the specified file
-// and returns both per-line and per-function statistics.
+// ("-" for standard input, see loader.ReadFile) and returns both per-line and
+// per-function statistics.
 // If showFrom is non-empty, only samples whose stacktrace contains the specified
 // function are included in the analysis.
 func LoadProfileDataWithFunctionStats(filename string, showFrom string) ([]*models.SourceLine, map[string]*models.FunctionStat, error) {
+	return LoadProfileDataWithOptions(filename, Options{ShowFrom: showFrom})
+}
+
+// LoadProfileDataWithOptions loads profile data from the specified file
+// and returns both per-line and per-function statistics selected by opts.
+// filename may be a glob pattern, the matching profiles are merged.
+func LoadProfileDataWithOptions(filename string, opts Options) ([]*models.SourceLine, map[string]*models.FunctionStat, error) {
+	return LoadProfilesWithOptions([]string{filename}, opts)
+}
+
+// LoadProfilesWithOptions is like LoadProfileDataWithOptions, but merges the profiles
+// of all filenames (see loader.ReadFiles) and analyzes the combined profile.
+func LoadProfilesWithOptions(filenames []string, opts Options) ([]*models.SourceLine, map[string]*models.FunctionStat, error) {
 	// Load profile data
-	data, err := os.ReadFile(filename)
+	data, err := loader.ReadFiles(filenames)
 	if err != nil {
 		return nil, nil, fmt.Errorf("error loading profile: %v", err)
 	}
 
 	// Analyze profile data
-	allLines, funcStats, err := AnalyzeWithFunctionStats(data, showFrom)
+	allLines, funcStats, err := AnalyzeWithOptions(data, opts)
 	if err != nil {
 		return nil, nil, fmt.Errorf("error analyzing profile: %v", err)
 	}
@@ -176,26 +335,41 @@ func LoadProfileData(filename string, showFrom string) ([]*models.SourceLine, er
 // GetTotalProfileTime calculates the total time by summing all sample values in the profile.
 // This returns the actual total profile time regardless of any filtering.
 func GetTotalProfileTime(filename string) (time.Duration, error) {
-	data, err := os.ReadFile(filename)
+	total, err := GetTotalProfileValue([]string{filename}, "")
+	return total.Duration(), err
+}
+
+// GetTotalProfileValue is like GetTotalProfileTime, but sums the sample type
+// selected by sampleIndex (see Options.SampleIndex) of the merged profiles of
+// filenames and keeps its unit.
+func GetTotalProfileValue(filenames []string, sampleIndex string) (models.Value, error) {
+	data, err := loader.ReadFiles(filenames)
 	if err != nil {
-		return 0, fmt.Errorf("error loading profile: %v", err)
+		return models.Value{}, fmt.Errorf("error loading profile: %v", err)
 	}
 
 	p, err := profile.ParseData(data)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse profile data: %w", err)
+		return models.Value{}, fmt.Errorf("failed to parse profile data: %w", err)
 	}
 
-	timeUnit := convTimeUnit(p.SampleType[1].Unit)
+	valueIdx, err := selectSampleIndex(p, sampleIndex)
+	if err != nil {
+		return models.Value{}, err
+	}
+	scale, unit, err := valueScale(p.SampleType[valueIdx])
+	if err != nil {
+		return models.Value{}, err
+	}
 	var total int64
 
 	for _, sample := range p.Sample {
-		if len(sample.Value) > 1 {
-			total += sample.Value[1]
+		if len(sample.Value) > valueIdx {
+			total += sample.Value[valueIdx]
 		}
 	}
 
-	return time.Duration(total) * timeUnit, nil
+	return models.Value{Amount: total * scale, Unit: unit}, nil
 }
 
 // GetCallerKNameSet retrieves the set of k-hop caller function names for a given callee.
@@ -223,14 +397,14 @@ func GetTotalProfileTime(filename string) (time.Duration, error) {
 //	- GetCallerKNameSet("profile.pprof", "baz", 3, "") returns ["main"]
 func GetCallerKNameSet(filename string, callee string, k int, showFrom string) (result []string, err error) {
 	// Load and parse profile data
-	data, err := os.ReadFile(filename)
+	data, err := loader.ReadFiles([]string{filename})
 	if err != nil {
 		return nil, fmt.Errorf("error loading profile: %v", err)
 	}
 
-	p, err := profile.ParseData(data)
+	p, err := parseProfile(data, Options{ShowFrom: showFrom})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse profile data: %w", err)
+		return nil, err
 	}
 
 	callerSet := make(map[string]struct{})
@@ -238,23 +412,6 @@ func GetCallerKNameSet(filename string, callee string, k int, showFrom string) (
 
 	// Process each sample's call stack
 	for _, sample := range p.Sample {
-		// Filter: skip sample if showFrom specified but not found in stacktrace
-		if showFrom != "" {
-			found := false
-		locationLoop:
-			for _, loc := range sample.Location {
-				for _, le := range loc.Line {
-					if le.Function != nil && le.Function.Name == showFrom {
-						found = true
-						break locationLoop
-					}
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-
 		// Search for callee in the call stack
 		for calleeIdx, loc := range sample.Location {
 			// Skip locations without lines
@@ -332,14 +489,14 @@ func GetCallerKNameSet(filename string, callee string, k int, showFrom string) (
 //	- GetCalleeKNameSet("profile.pprof", "main", 3, "") returns ["baz"]
 func GetCalleeKNameSet(filename string, caller string, k int, showFrom string) (result []string, err error) {
 	// Load and parse profile data
-	data, err := os.ReadFile(filename)
+	data, err := loader.ReadFiles([]string{filename})
 	if err != nil {
 		return nil, fmt.Errorf("error loading profile: %v", err)
 	}
 
-	p, err := profile.ParseData(data)
+	p, err := parseProfile(data, Options{ShowFrom: showFrom})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse profile data: %w", err)
+		return nil, err
 	}
 
 	calleeSet := make(map[string]struct{})
@@ -347,23 +504,6 @@ func GetCalleeKNameSet(filename string, caller string, k int, showFrom string) (
 
 	// Process each sample's call stack
 	for _, sample := range p.Sample {
-		// Filter: skip sample if showFrom specified but not found in stacktrace
-		if showFrom != "" {
-			found := false
-		locationLoop:
-			for _, loc := range sample.Location {
-				for _, le := range loc.Line {
-					if le.Function != nil && le.Function.Name == showFrom {
-						found = true
-						break locationLoop
-					}
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-
 		// Search for caller in the call stack
 		for callerIdx, loc := range sample.Location {
 			// Skip locations without lines
@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/imexporter"
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsymbolizedSamples(t *testing.T) {
+	data := syntheticProfile(t, []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}}, []syntheticSample{
+		{stack: []string{"main.main main.go:5"}, values: []int64{1, 10}},
+		{stack: []string{"0x2000", "main.main main.go:5"}, values: []int64{3, 30}},
+	})
+
+	// The test binary has no code at 0x2000
+	bin, err := os.Executable()
+	assert.Nil(t, err)
+	symbolized, unsymbolized, total, err := Symbolize(data, bin)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, unsymbolized)
+	assert.Equal(t, 2, total)
+	_, err = profile.ParseData(symbolized)
+	assert.Nil(t, err)
+
+	lines, funcStats, err := AnalyzeWithOptions(data, Options{})
+	assert.Nil(t, err)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, UnsymbolizedFunction, lines[1].FunctionName)
+	assert.Equal(t, models.Value{Amount: 30, Unit: models.UnitNanoseconds}, lines[1].Flat)
+	assert.Equal(t, models.Value{Amount: 40, Unit: models.UnitNanoseconds}, lines[0].Cum)
+	assert.Equal(t, models.Value{Amount: 30, Unit: models.UnitNanoseconds}, funcStats[UnsymbolizedFunction].Cum)
+
+	_, _, err = AnalyzeWithOptions(data, Options{Binary: common.AbsPathFromRoot("test/loop/cpu.pprof")})
+	assert.NotNil(t, err)
+}
+
+func TestRecursiveCum(t *testing.T) {
+	cpu := []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}}
+	data := syntheticProfile(t, cpu, []syntheticSample{
+		// Direct recursion: parse -> parse -> parse -> main
+		{stack: []string{"parse", "parse", "parse", "main"}, values: []int64{1, 10}},
+		// Mutual recursion: expr -> term -> expr -> parse -> main
+		{stack: []string{"expr", "term", "expr", "parse", "main"}, values: []int64{2, 20}},
+	})
+	ns := func(n int64) models.Value { return models.Value{Amount: n, Unit: models.UnitNanoseconds} }
+
+	lines, funcStats, err := AnalyzeWithOptions(data, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, ns(30), funcStats["main"].Cum)
+	assert.Equal(t, ns(30), funcStats["parse"].Cum)
+	assert.Equal(t, ns(10), funcStats["parse"].Flat)
+	assert.Equal(t, ns(20), funcStats["expr"].Cum)
+	assert.Equal(t, ns(20), funcStats["term"].Cum)
+	for _, line := range lines {
+		assert.LessOrEqual(t, line.Cum.Amount, int64(30), line.FunctionName)
+	}
+
+	_, funcStats, err = AnalyzeWithOptions(data, Options{PerFrameCum: true})
+	assert.Nil(t, err)
+	assert.Equal(t, ns(30), funcStats["main"].Cum)
+	assert.Equal(t, ns(50), funcStats["parse"].Cum)
+	assert.Equal(t, ns(40), funcStats["expr"].Cum)
+	assert.Equal(t, ns(10), funcStats["parse"].Flat)
+}
+
+func TestInlinedFlat(t *testing.T) {
+	data := syntheticProfile(t, []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}}, []syntheticSample{
+		// main.inner is inlined into main.outer, which is inlined into main.main
+		{stack: []string{"main.inner main.go:3;main.outer main.go:10;main.main main.go:20"}, values: []int64{1, 10}},
+		// main.outer is also called without inlining
+		{stack: []string{"main.outer main.go:11", "main.main main.go:21"}, values: []int64{2, 20}},
+	})
+	ns := func(n int64) models.Value { return models.Value{Amount: n, Unit: models.UnitNanoseconds} }
+
+	lines, funcStats, err := AnalyzeWithOptions(data, Options{})
+	assert.Nil(t, err)
+	var flat models.Value
+	byLine := make(map[int]*models.SourceLine)
+	for _, line := range lines {
+		flat = flat.Add(line.Flat)
+		byLine[line.LineNumber] = line
+	}
+	// The flat of the inlined location is counted once
+	assert.Equal(t, ns(30), flat)
+	assert.Equal(t, ns(10), byLine[3].Flat)
+	assert.Equal(t, ns(0), byLine[10].Flat)
+	assert.Equal(t, ns(10), byLine[10].Cum)
+	assert.Equal(t, ns(0), byLine[20].Flat)
+	assert.Equal(t, []string{"main.outer"}, byLine[3].InlinedInto)
+	assert.Equal(t, []string{"main.main"}, byLine[10].InlinedInto)
+	assert.Empty(t, byLine[11].InlinedInto)
+	assert.Empty(t, byLine[20].InlinedInto)
+	assert.Equal(t, ns(20), funcStats["main.outer"].Flat)
+	assert.Equal(t, ns(0), funcStats["main.main"].Flat)
+
+	var csvBuf bytes.Buffer
+	assert.Nil(t, imexporter.New().Export(&csvBuf, lines, ""))
+	assert.Contains(t, csvBuf.String(), "main.go,3,main.inner,10ns,10ns,nanoseconds,true,main.outer\n")
+	assert.Contains(t, csvBuf.String(), "main.go,11,main.outer,20ns,20ns,nanoseconds,false,\n")
+	imported := imexporter.Import(&csvBuf)
+	assert.Equal(t, lines, imported)
+}
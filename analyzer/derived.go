@@ -0,0 +1,47 @@
+package analyzer
+
+import (
+	"math"
+	"slices"
+
+	"github.com/Lslightly/pprof2csv/models"
+)
+
+// DeriveMetrics computes every metric of models.DerivedMetrics whose sample types
+// are in sampleTypes, as returned by AnalyzeSampleTypes, for each line.
+// The values are stored in FlatDerived and CumDerived of the lines, e.g. flat IPC is
+// the flat instructions of the line divided by its flat cycles.
+// It returns the applied metrics, FlatDerived[i] and CumDerived[i] belong to the i-th one.
+func DeriveMetrics(sampleTypes []string, lines []*models.MultiSourceLine) []models.DerivedMetric {
+	var applied []models.DerivedMetric
+	var numIdxs, denomIdxs []int
+	for _, m := range models.DerivedMetrics {
+		numIdx := slices.Index(sampleTypes, m.Numerator)
+		denomIdx := slices.Index(sampleTypes, m.Denominator)
+		if numIdx < 0 || denomIdx < 0 {
+			continue
+		}
+		applied = append(applied, m)
+		numIdxs = append(numIdxs, numIdx)
+		denomIdxs = append(denomIdxs, denomIdx)
+	}
+
+	for _, line := range lines {
+		line.FlatDerived = make([]float64, len(applied))
+		line.CumDerived = make([]float64, len(applied))
+		for i := range applied {
+			line.FlatDerived[i] = ratio(line.Flat[numIdxs[i]], line.Flat[denomIdxs[i]])
+			line.CumDerived[i] = ratio(line.Cum[numIdxs[i]], line.Cum[denomIdxs[i]])
+		}
+	}
+
+	return applied
+}
+
+// ratio returns num / denom, or NaN if denom is zero.
+func ratio(num, denom models.Value) float64 {
+	if denom.Amount == 0 {
+		return math.NaN()
+	}
+	return float64(num.Amount) / float64(denom.Amount)
+}
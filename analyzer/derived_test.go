@@ -0,0 +1,46 @@
+package analyzer
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/Lslightly/pprof2csv/imexporter"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveMetrics(t *testing.T) {
+	count := func(typ string) *profile.ValueType { return &profile.ValueType{Type: typ, Unit: "count"} }
+	sampleTypes := []*profile.ValueType{count("cycles"), count("instructions"), count("cache-misses"), count("cache-references")}
+	data := syntheticProfile(t, sampleTypes, []syntheticSample{
+		{stack: []string{"main.compute", "main.main"}, values: []int64{100, 250, 0, 0}},
+		{stack: []string{"main.load", "main.main"}, values: []int64{200, 50, 30, 60}},
+	})
+
+	types, lines, err := AnalyzeSampleTypes(data, Options{}, nil)
+	assert.Nil(t, err)
+	derived := DeriveMetrics(types, lines)
+	assert.Equal(t, []string{"ipc", "cpi", "cache_miss_ratio"}, []string{derived[0].Name, derived[1].Name, derived[2].Name})
+
+	for _, line := range lines {
+		switch line.FunctionName {
+		case "main.compute":
+			assert.Equal(t, 2.5, line.FlatDerived[0])
+			assert.True(t, math.IsNaN(line.FlatDerived[2]))
+		case "main.load":
+			assert.Equal(t, 0.25, line.FlatDerived[0])
+			assert.Equal(t, 0.5, line.FlatDerived[2])
+		case "main.main":
+			assert.True(t, math.IsNaN(line.FlatDerived[0]))
+			assert.Equal(t, 1.0, line.CumDerived[0])
+		}
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, imexporter.New().ExportMultiWithDerived(&buf, types, derived, lines, ""))
+	assert.Contains(t, buf.String(), "flat_ipc,cum_ipc,flat_cpi,cum_cpi,flat_cache_miss_ratio,cum_cache_miss_ratio,inlined,inlined_into\n")
+	importedTypes, imported := imexporter.ImportMulti(&buf)
+	assert.Equal(t, types, importedTypes)
+	assert.Len(t, imported[0].Cum, len(types))
+}
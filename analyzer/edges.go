@@ -0,0 +1,147 @@
+package analyzer
+
+import (
+	"sort"
+
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/google/pprof/profile"
+)
+
+// frame is a frame of the stack of a sample, with inlined calls expanded.
+type frame struct {
+	// line is the source line of the frame, its Function is nil for locations
+	// without line information
+	line profile.Line
+	// inlined tells whether the frame is inlined into the next frame, its caller
+	inlined bool
+}
+
+// stackFrames returns the frames of sample from the leaf to the root. The lines of
+// a location are ordered from the innermost inlined function to the outermost one.
+func stackFrames(sample *profile.Sample) []frame {
+	var frames []frame
+	for _, loc := range sample.Location {
+		if len(loc.Line) == 0 {
+			frames = append(frames, frame{})
+			continue
+		}
+		for j, line := range loc.Line {
+			frames = append(frames, frame{line: line, inlined: j+1 < len(loc.Line)})
+		}
+	}
+	return frames
+}
+
+// named reports whether the frame has a function name.
+func (f frame) named() bool {
+	return f.line.Function != nil && f.line.Function.Name != ""
+}
+
+// sampleEdges calls fn for each pair of adjacent frames of sample, from the leaf to
+// the root. Frames without function name break the stack, no edge leads to or from them.
+func sampleEdges(sample *profile.Sample, fn func(callee, caller frame)) {
+	frames := stackFrames(sample)
+	for k := 0; k+1 < len(frames); k++ {
+		if frames[k].named() && frames[k+1].named() {
+			fn(frames[k], frames[k+1])
+		}
+	}
+}
+
+// edgeKey identifies an edge of the call graph.
+type edgeKey struct {
+	caller, file string
+	line         int64
+	callee       string
+	inlined      bool
+}
+
+// AnalyzeEdges parses the pprof profile data and aggregates the sample type selected
+// by opts per call graph edge, i.e. per caller function, call site line and callee
+// function of adjacent frames. Calls of inlined functions are edges as well, marked
+// as Inlined. Frames without line information break the stack, no edge leads to or
+// from them. A sample counts at most once per edge, even if the edge appears several
+// times in its stack, unless opts.PerFrameCum is set.
+// The result is sorted by weight descending.
+func AnalyzeEdges(data []byte, opts Options) ([]*models.EdgeStat, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	valueIdx, err := selectSampleIndex(p, opts.SampleIndex)
+	if err != nil {
+		return nil, err
+	}
+	scale, unit, err := valueScale(p.SampleType[valueIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	edgeMap := make(map[edgeKey]*models.EdgeStat)
+	// Edges whose weight already includes the current sample
+	seen := make(map[edgeKey]bool)
+	for _, sample := range p.Sample {
+		if len(sample.Value) <= valueIdx {
+			continue
+		}
+		delta := models.Value{Amount: sample.Value[valueIdx] * scale, Unit: unit}
+
+		clear(seen)
+		sampleEdges(sample, func(callee, caller frame) {
+			key := edgeKey{
+				caller:  caller.line.Function.Name,
+				file:    caller.line.Function.Filename,
+				line:    caller.line.Line,
+				callee:  callee.line.Function.Name,
+				inlined: callee.inlined,
+			}
+			if seen[key] && !opts.PerFrameCum {
+				return
+			}
+			seen[key] = true
+
+			if es, exists := edgeMap[key]; exists {
+				es.Weight = es.Weight.Add(delta)
+			} else {
+				edgeMap[key] = &models.EdgeStat{
+					CallerFunction: key.caller,
+					CallerFilename: key.file,
+					CallerLine:     int(key.line),
+					CalleeFunction: key.callee,
+					Inlined:        key.inlined,
+					Weight:         delta,
+				}
+			}
+		})
+	}
+
+	result := make([]*models.EdgeStat, 0, len(edgeMap))
+	for _, es := range edgeMap {
+		result = append(result, es)
+	}
+
+	// Sort by weight descending, then by every field of the edge key for a stable output
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Weight.Amount != b.Weight.Amount {
+			return a.Weight.Amount > b.Weight.Amount
+		}
+		if a.CallerFunction != b.CallerFunction {
+			return a.CallerFunction < b.CallerFunction
+		}
+		if a.CallerFilename != b.CallerFilename {
+			return a.CallerFilename < b.CallerFilename
+		}
+		if a.CallerLine != b.CallerLine {
+			return a.CallerLine < b.CallerLine
+		}
+		if a.CalleeFunction != b.CalleeFunction {
+			return a.CalleeFunction < b.CalleeFunction
+		}
+		// An inlined call follows a regular call of the same site
+		return !a.Inlined && b.Inlined
+	})
+
+	return result, nil
+}
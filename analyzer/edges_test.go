@@ -0,0 +1,68 @@
+package analyzer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Lslightly/pprof2csv/imexporter"
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeEdges(t *testing.T) {
+	const (
+		mallocLoc = "runtime.mallocgc malloc.go:100"
+		// main.alloc is inlined into main.walk
+		allocLoc   = "main.alloc main.go:5;main.walk main.go:20"
+		walkLoc    = "main.walk main.go:21"
+		unknownLoc = "0x1000"
+	)
+	data := syntheticProfile(t, []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}}, []syntheticSample{
+		// main.walk recurses twice before allocating
+		{stack: []string{mallocLoc, allocLoc, walkLoc, walkLoc}, values: []int64{1, 10}},
+		{stack: []string{mallocLoc, walkLoc}, values: []int64{2, 20}},
+		{stack: []string{unknownLoc, walkLoc}, values: []int64{4, 40}},
+	})
+
+	edges, err := AnalyzeEdges(data, Options{})
+	assert.Nil(t, err)
+	ns := func(n int64) models.Value { return models.Value{Amount: n, Unit: models.UnitNanoseconds} }
+	assert.Equal(t, []*models.EdgeStat{
+		{CallerFunction: "main.walk", CallerFilename: "main.go", CallerLine: 21, CalleeFunction: "runtime.mallocgc", Weight: ns(20)},
+		{CallerFunction: "main.alloc", CallerFilename: "main.go", CallerLine: 5, CalleeFunction: "runtime.mallocgc", Weight: ns(10)},
+		{CallerFunction: "main.walk", CallerFilename: "main.go", CallerLine: 20, CalleeFunction: "main.alloc", Inlined: true, Weight: ns(10)},
+		{CallerFunction: "main.walk", CallerFilename: "main.go", CallerLine: 21, CalleeFunction: "main.walk", Weight: ns(10)},
+	}, edges)
+
+	// Each recursive call counts with PerFrameCum
+	edges, err = AnalyzeEdges(data, Options{PerFrameCum: true})
+	assert.Nil(t, err)
+	assert.Equal(t, ns(20), edges[0].Weight)
+	assert.Equal(t, "main.walk", edges[0].CalleeFunction)
+
+	var csvBuf bytes.Buffer
+	assert.Nil(t, imexporter.New().ExportEdges(&csvBuf, edges, ""))
+	assert.Contains(t, csvBuf.String(), "caller,caller_file,caller_line,callee,weight,unit,inlined\n")
+	assert.Contains(t, csvBuf.String(), "main.walk,main.go,20,main.alloc,10ns,nanoseconds,true\n")
+
+	// Edges of equal weight are ordered by every key field, e.g. callers of the same
+	// name and line in different files, or the same call site inlined and not
+	data = syntheticProfile(t, []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}}, []syntheticSample{
+		{stack: []string{"main.g g.go:1", "main.f b.go:10"}, values: []int64{10}},
+		{stack: []string{"main.g g.go:1", "main.f a.go:10"}, values: []int64{10}},
+		{stack: []string{"main.g g.go:1;main.h h.go:10"}, values: []int64{10}},
+		{stack: []string{"main.g g.go:1", "main.h h.go:10"}, values: []int64{10}},
+	})
+	want := []*models.EdgeStat{
+		{CallerFunction: "main.f", CallerFilename: "a.go", CallerLine: 10, CalleeFunction: "main.g", Weight: ns(10)},
+		{CallerFunction: "main.f", CallerFilename: "b.go", CallerLine: 10, CalleeFunction: "main.g", Weight: ns(10)},
+		{CallerFunction: "main.h", CallerFilename: "h.go", CallerLine: 10, CalleeFunction: "main.g", Weight: ns(10)},
+		{CallerFunction: "main.h", CallerFilename: "h.go", CallerLine: 10, CalleeFunction: "main.g", Inlined: true, Weight: ns(10)},
+	}
+	for range 10 {
+		edges, err = AnalyzeEdges(data, Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, want, edges)
+	}
+}
@@ -0,0 +1,225 @@
+package analyzer
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// Filter selects the samples and stack frames of a profile by regular expressions,
+// with the semantics of the go tool pprof options of the same names. A frame matches
+// if the regular expression matches its function name, its source file or the file
+// of its mapping. Empty fields do not filter.
+type Filter struct {
+	// Focus keeps only the samples with a frame matching it.
+	Focus string
+	// Ignore drops the samples with a frame matching it.
+	Ignore string
+	// Hide removes the frames matching it from the samples.
+	Hide string
+	// Show removes the frames not matching it from the samples.
+	Show string
+	// PruneFrom removes the callees of the frames matching it from the samples,
+	// e.g. "runtime.mallocgc" attributes the time spent inside the allocator to mallocgc.
+	PruneFrom string
+	// TagFocus keeps only the samples with a label matching it, see compileTagFilter.
+	TagFocus string
+	// TagIgnore drops the samples with a label matching it.
+	TagIgnore string
+}
+
+// AddFilterFlags defines the -focus, -ignore, -hide, -show, -prune_from, -tagfocus
+// and -tagignore flags on fs, which set the fields of f.
+func AddFilterFlags(fs *flag.FlagSet, f *Filter) {
+	fs.StringVar(&f.Focus, "focus", "", "Only include samples with a frame matching this regexp (function name or file)")
+	fs.StringVar(&f.Ignore, "ignore", "", "Exclude samples with a frame matching this regexp")
+	fs.StringVar(&f.Hide, "hide", "", "Remove the frames matching this regexp from the samples")
+	fs.StringVar(&f.Show, "show", "", "Only keep the frames matching this regexp in the samples")
+	fs.StringVar(&f.PruneFrom, "prune_from", "", "Remove the callees of the frames matching this regexp from the samples")
+	fs.StringVar(&f.TagFocus, "tagfocus", "", "Only include samples with a label matching [key=]regexp[,regexp] or a numeric label in [key=]min:max, e.g. request=login or bytes=1kb:")
+	fs.StringVar(&f.TagIgnore, "tagignore", "", "Exclude samples with a label matching [key=]regexp[,regexp] or a numeric label in [key=]min:max")
+}
+
+// apply filters the samples and frames of p, first by Focus, Ignore, Hide and Show,
+// then by TagFocus and TagIgnore and finally by PruneFrom, like go tool pprof.
+func (f Filter) apply(p *profile.Profile) error {
+	focus, err := compileFilter("focus", f.Focus)
+	if err != nil {
+		return err
+	}
+	ignore, err := compileFilter("ignore", f.Ignore)
+	if err != nil {
+		return err
+	}
+	hide, err := compileFilter("hide", f.Hide)
+	if err != nil {
+		return err
+	}
+	show, err := compileFilter("show", f.Show)
+	if err != nil {
+		return err
+	}
+	pruneFrom, err := compileFilter("prune_from", f.PruneFrom)
+	if err != nil {
+		return err
+	}
+	tagFocus, err := compileTagFilter("tagfocus", f.TagFocus)
+	if err != nil {
+		return err
+	}
+	tagIgnore, err := compileTagFilter("tagignore", f.TagIgnore)
+	if err != nil {
+		return err
+	}
+
+	p.FilterSamplesByName(focus, ignore, hide, show)
+	if tagFocus != nil || tagIgnore != nil {
+		p.FilterSamplesByTag(tagFocus, tagIgnore)
+	}
+	if pruneFrom != nil {
+		p.PruneFrom(pruneFrom)
+	}
+	return nil
+}
+
+// ShowFromFocus returns the Focus of the -show_from option, which keeps the samples
+// whose stacktrace contains exactly the function named name.
+func ShowFromFocus(name string) string {
+	return "^" + regexp.QuoteMeta(name) + "$"
+}
+
+// compileFilter compiles the regexp value of the filter name. It returns nil if
+// value is empty.
+func compileFilter(name, value string) (*regexp.Regexp, error) {
+	if value == "" {
+		return nil, nil
+	}
+	rx, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s regexp: %v", name, err)
+	}
+	return rx, nil
+}
+
+// tagRangeRx matches a numeric label value with an optional unit, e.g. 64 or 32kb.
+var tagRangeRx = regexp.MustCompile(`^([+-]?[[:digit:]]+)([[:alpha:]]*)$`)
+
+// tagUnits are the scales of the units of numeric label ranges. Byte units are
+// powers of 1024, like the bytes label of heap profiles.
+var tagUnits = map[string]int64{
+	"": 1, "b": 1, "kb": 1 << 10, "mb": 1 << 20, "gb": 1 << 30, "tb": 1 << 40,
+	"ns": 1, "us": 1e3, "ms": 1e6, "s": 1e9,
+}
+
+// compileTagFilter compiles the label filter value of the filter name, with the syntax
+// of go tool pprof -tagfocus:
+//   - [key=]min:max, [key=]min:, [key=]:max or [key=]value match numeric labels in the
+//     range, e.g. bytes=1kb:64kb. Values are integers with an optional byte or time unit
+//   - key=regexp[,regexp] matches string labels key whose value matches a regexp
+//   - regexp[,regexp] matches samples where each regexp matches a string label key:value
+//
+// It returns nil if value is empty.
+func compileTagFilter(name, value string) (profile.TagMatch, error) {
+	if value == "" {
+		return nil, nil
+	}
+	key, value, hasKey := strings.Cut(value, "=")
+	if !hasKey {
+		key, value = "", key
+	}
+
+	if inRange := parseTagRange(value); inRange != nil {
+		return func(s *profile.Sample) bool {
+			for k, vals := range s.NumLabel {
+				if key != "" && k != key {
+					continue
+				}
+				if slices.ContainsFunc(vals, inRange) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	var rxs []*regexp.Regexp
+	for _, expr := range strings.Split(value, ",") {
+		rx, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s regexp: %v", name, err)
+		}
+		rxs = append(rxs, rx)
+	}
+	if key != "" {
+		return func(s *profile.Sample) bool {
+			for _, rx := range rxs {
+				if slices.ContainsFunc(s.Label[key], rx.MatchString) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return func(s *profile.Sample) bool {
+	nextRx:
+		for _, rx := range rxs {
+			for k, vals := range s.Label {
+				for _, val := range vals {
+					if rx.MatchString(k + ":" + val) {
+						continue nextRx
+					}
+				}
+			}
+			return false
+		}
+		return true
+	}, nil
+}
+
+// parseTagRange returns whether a numeric label value is in the range filter, see
+// compileTagFilter, or nil if filter is not a range.
+func parseTagRange(filter string) func(int64) bool {
+	parse := func(s string) (int64, bool) {
+		m := tagRangeRx.FindStringSubmatch(s)
+		if m == nil {
+			return 0, false
+		}
+		scale, ok := tagUnits[strings.ToLower(m[2])]
+		if !ok {
+			return 0, false
+		}
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		return n * scale, err == nil
+	}
+
+	lo, hi, isRange := strings.Cut(filter, ":")
+	if !isRange {
+		v, ok := parse(filter)
+		if !ok {
+			return nil
+		}
+		return func(n int64) bool { return n == v }
+	}
+	if lo == "" && hi == "" {
+		return nil
+	}
+	from, to := int64(math.MinInt64), int64(math.MaxInt64)
+	var ok bool
+	if lo != "" {
+		if from, ok = parse(lo); !ok {
+			return nil
+		}
+	}
+	if hi != "" {
+		if to, ok = parse(hi); !ok {
+			return nil
+		}
+	}
+	return func(n int64) bool { return from <= n && n <= to }
+}
@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	const (
+		mainLoc   = "main.main main.go:10"
+		parseLoc  = "main.parse parse.go:20"
+		mallocLoc = "runtime.mallocgc $GOROOT/src/runtime/malloc.go:30"
+		memclrLoc = "runtime.memclrNoHeapPointers $GOROOT/src/runtime/memclr_amd64.s:40"
+	)
+	data := syntheticProfile(t, []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}}, []syntheticSample{
+		{stack: []string{memclrLoc, mallocLoc, parseLoc, mainLoc}, values: []int64{1, 10}},
+		{stack: []string{parseLoc, mainLoc}, values: []int64{2, 20}},
+		{stack: []string{mallocLoc, mainLoc}, values: []int64{4, 40}},
+	})
+
+	// stats returns the flat and cum of each function analyzed with opts, in nanoseconds
+	stats := func(opts Options) map[string][2]int64 {
+		_, funcStats, err := AnalyzeWithOptions(data, opts)
+		assert.Nil(t, err)
+		result := make(map[string][2]int64)
+		for name, fs := range funcStats {
+			result[name] = [2]int64{fs.Flat.Amount, fs.Cum.Amount}
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string][2]int64{
+		"main.main": {0, 30}, "main.parse": {20, 30}, "runtime.mallocgc": {0, 10}, "runtime.memclrNoHeapPointers": {10, 10},
+	}, stats(Options{Filter: Filter{Focus: "parse"}}))
+	assert.Equal(t, map[string][2]int64{
+		"main.main": {0, 40}, "runtime.mallocgc": {40, 40},
+	}, stats(Options{Filter: Filter{Ignore: `parse\.go`}}))
+	assert.Equal(t, map[string][2]int64{
+		"main.main": {40, 70}, "main.parse": {30, 30},
+	}, stats(Options{Filter: Filter{Hide: `^runtime\.`}}))
+	assert.Equal(t, map[string][2]int64{
+		"runtime.mallocgc": {40, 50}, "runtime.memclrNoHeapPointers": {10, 10},
+	}, stats(Options{Filter: Filter{Show: `\$GOROOT/`}}))
+	assert.Equal(t, map[string][2]int64{
+		"main.main": {0, 70}, "main.parse": {20, 30}, "runtime.mallocgc": {50, 50},
+	}, stats(Options{Filter: Filter{PruneFrom: "runtime.mallocgc"}}))
+
+	// ShowFrom is an alias of Focus matching the exact function name
+	assert.Equal(t, stats(Options{Filter: Filter{Focus: ShowFromFocus("main.parse")}}), stats(Options{ShowFrom: "main.parse"}))
+	assert.Empty(t, stats(Options{ShowFrom: "parse"}))
+	_, _, err := AnalyzeWithOptions(data, Options{ShowFrom: "main.parse", Filter: Filter{Focus: "main"}})
+	assert.ErrorContains(t, err, "cannot be combined")
+
+	_, _, err = AnalyzeWithOptions(data, Options{Filter: Filter{Hide: "("}})
+	assert.ErrorContains(t, err, "invalid hide regexp")
+}
+
+func TestTagFilter(t *testing.T) {
+	heapPath := common.AbsPathFromRoot("test/heap/heap.pprof")
+	// heap.go allocates 64B objects in allocSmall and 64KiB objects in allocLarge
+	_, funcStats, err := LoadProfileDataWithOptions(heapPath, Options{Filter: Filter{TagFocus: "bytes=1kb:"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6553600), funcStats["main.allocLarge"].Cum.Amount)
+	assert.Nil(t, funcStats["main.allocSmall"])
+
+	_, funcStats, err = LoadProfileDataWithOptions(heapPath, Options{Filter: Filter{TagFocus: "64", TagIgnore: "bytes=:32"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(64000), funcStats["main.allocSmall"].Cum.Amount)
+	assert.Nil(t, funcStats["main.allocLarge"])
+
+	data := syntheticProfile(t, []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}}, []syntheticSample{
+		{stack: []string{"main.login", "main.serve"}, values: []int64{10}, labels: map[string][]string{"request": {"login"}, "tenant": {"a"}}},
+		{stack: []string{"main.search", "main.serve"}, values: []int64{20}, labels: map[string][]string{"request": {"search"}, "tenant": {"b"}}},
+		{stack: []string{"main.gc"}, values: []int64{40}},
+	})
+	stats := func(filter Filter) map[string]int64 {
+		_, funcStats, err := AnalyzeWithOptions(data, Options{Filter: filter})
+		assert.Nil(t, err)
+		result := make(map[string]int64)
+		for name, fs := range funcStats {
+			result[name] = fs.Cum.Amount
+		}
+		return result
+	}
+	assert.Equal(t, map[string]int64{"main.login": 10, "main.serve": 10}, stats(Filter{TagFocus: "request=log"}))
+	assert.Equal(t, map[string]int64{"main.login": 10, "main.search": 20, "main.serve": 30}, stats(Filter{TagFocus: "request=login,search"}))
+	// Without key, every regexp has to match a key:value label
+	assert.Equal(t, map[string]int64{"main.search": 20, "main.serve": 20}, stats(Filter{TagFocus: "request:s,tenant:b"}))
+	assert.Equal(t, map[string]int64{"main.search": 20, "main.serve": 20, "main.gc": 40}, stats(Filter{TagIgnore: "tenant=a"}))
+
+	_, _, err = AnalyzeWithOptions(data, Options{Filter: Filter{TagFocus: "request=("}})
+	assert.ErrorContains(t, err, "invalid tagfocus regexp")
+}
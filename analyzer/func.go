@@ -1,16 +1,14 @@
 package analyzer
 
 import (
-	"time"
-
 	"github.com/Lslightly/pprof2csv/models"
 )
 
-func SumFuncTime(funcStats map[string]*models.FunctionStat, satisfy func(name string) bool) (flat time.Duration, cum time.Duration) {
+func SumFuncTime(funcStats map[string]*models.FunctionStat, satisfy func(name string) bool) (flat models.Value, cum models.Value) {
 	for name, stat := range funcStats {
 		if satisfy(name) {
-			flat += stat.Flat
-			cum += stat.Cum
+			flat = flat.Add(stat.Flat)
+			cum = cum.Add(stat.Cum)
 		}
 	}
 	return
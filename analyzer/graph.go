@@ -0,0 +1,138 @@
+package analyzer
+
+import (
+	"sort"
+
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/models"
+)
+
+// Default node and edge fractions of go tool pprof, see PruneCallGraph.
+const (
+	DefaultNodeFraction = 0.005
+	DefaultEdgeFraction = 0.001
+)
+
+// BuildCallGraph parses the pprof profile data and builds its call graph for the sample
+// type selected by opts. The nodes are the functions with their flat and cum as in
+// AnalyzeWithOptions, the edges are weighted like AnalyzeEdges, but per caller and
+// callee function regardless of the call site line.
+func BuildCallGraph(data []byte, opts Options) (*models.CallGraph, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, funcStats, err := analyzeProfile(p, opts)
+	if err != nil {
+		return nil, err
+	}
+	valueIdx, err := selectSampleIndex(p, opts.SampleIndex)
+	if err != nil {
+		return nil, err
+	}
+	scale, unit, err := valueScale(p.SampleType[valueIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	filenames := make(map[string]string)
+	for _, fn := range p.Function {
+		filenames[fn.Name] = fn.Filename
+	}
+	graph := &models.CallGraph{Total: models.Value{Unit: unit}}
+	nodes := make(map[string]*models.GraphNode)
+	for name, fs := range funcStats {
+		node := &models.GraphNode{FunctionName: name, Filename: filenames[name], Flat: fs.Flat, Cum: fs.Cum}
+		graph.Nodes = append(graph.Nodes, node)
+		nodes[name] = node
+	}
+
+	type edgeKey struct{ caller, callee string }
+	edges := make(map[edgeKey]*models.GraphEdge)
+	// Edges whose weight already includes the current sample
+	seen := make(map[edgeKey]bool)
+	for _, sample := range p.Sample {
+		if len(sample.Value) <= valueIdx {
+			continue
+		}
+		delta := models.Value{Amount: sample.Value[valueIdx] * scale, Unit: unit}
+		graph.Total = graph.Total.Add(delta)
+
+		clear(seen)
+		sampleEdges(sample, func(callee, caller frame) {
+			key := edgeKey{caller: caller.line.Function.Name, callee: callee.line.Function.Name}
+			// Functions without source file have no node, see analyzeProfile
+			if nodes[key.caller] == nil || nodes[key.callee] == nil {
+				return
+			}
+			e, exists := edges[key]
+			if !exists {
+				e = &models.GraphEdge{Caller: nodes[key.caller], Callee: nodes[key.callee], Weight: models.Value{Unit: unit}, Inlined: true}
+				edges[key] = e
+				graph.Edges = append(graph.Edges, e)
+			}
+			// Like go tool pprof, an edge is inlined only if all its calls are
+			e.Inlined = e.Inlined && callee.inlined
+			if seen[key] && !opts.PerFrameCum {
+				return
+			}
+			seen[key] = true
+			e.Weight = e.Weight.Add(delta)
+		})
+	}
+
+	sortCallGraph(graph)
+	return graph, nil
+}
+
+// PruneCallGraph removes the nodes of graph whose cum is less than nodeFraction of
+// graph.Total, and the edges whose weight is less than edgeFraction of graph.Total or
+// that lead to or from a removed node, like go tool pprof -nodefraction and
+// -edgefraction. The nodes are numbered anew.
+func PruneCallGraph(graph *models.CallGraph, nodeFraction, edgeFraction float64) {
+	total := float64(common.Abs(graph.Total.Amount))
+	kept := make(map[*models.GraphNode]bool)
+	nodes := graph.Nodes[:0]
+	for _, node := range graph.Nodes {
+		if float64(common.Abs(node.Cum.Amount)) >= nodeFraction*total {
+			nodes = append(nodes, node)
+			kept[node] = true
+		}
+	}
+	graph.Nodes = nodes
+
+	edges := graph.Edges[:0]
+	for _, e := range graph.Edges {
+		if kept[e.Caller] && kept[e.Callee] && float64(common.Abs(e.Weight.Amount)) >= edgeFraction*total {
+			edges = append(edges, e)
+		}
+	}
+	graph.Edges = edges
+
+	sortCallGraph(graph)
+}
+
+// sortCallGraph sorts the nodes by cum and the edges by weight descending, with
+// ties broken by name, and numbers the nodes.
+func sortCallGraph(graph *models.CallGraph) {
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		a, b := graph.Nodes[i], graph.Nodes[j]
+		if a.Cum.Amount != b.Cum.Amount {
+			return a.Cum.Amount > b.Cum.Amount
+		}
+		return a.FunctionName < b.FunctionName
+	})
+	for i, node := range graph.Nodes {
+		node.ID = i + 1
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.Weight.Amount != b.Weight.Amount {
+			return a.Weight.Amount > b.Weight.Amount
+		}
+		if a.Caller.ID != b.Caller.ID {
+			return a.Caller.ID < b.Caller.ID
+		}
+		return a.Callee.ID < b.Callee.ID
+	})
+}
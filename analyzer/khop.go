@@ -0,0 +1,153 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Lslightly/pprof2csv/loader"
+	"github.com/Lslightly/pprof2csv/models"
+)
+
+// GetCallerKStats is like GetCallerKNameSet, but weighs each k-hop caller of callee with
+// the value of the sample type selected by opts, see AnalyzeKHop.
+func GetCallerKStats(filename string, callee string, k int, withPaths bool, opts Options) ([]*models.KHopStat, error) {
+	data, err := loader.ReadFiles([]string{filename})
+	if err != nil {
+		return nil, fmt.Errorf("error loading profile: %v", err)
+	}
+	return AnalyzeKHop(data, opts, callee, k, withPaths)
+}
+
+// GetCalleeKStats is like GetCalleeKNameSet, but weighs each k-hop callee of caller with
+// the value of the sample type selected by opts, see AnalyzeKHop.
+func GetCalleeKStats(filename string, caller string, k int, withPaths bool, opts Options) ([]*models.KHopStat, error) {
+	data, err := loader.ReadFiles([]string{filename})
+	if err != nil {
+		return nil, fmt.Errorf("error loading profile: %v", err)
+	}
+	return AnalyzeKHop(data, opts, caller, -k, withPaths)
+}
+
+// AnalyzeKHop parses the pprof profile data and aggregates, for each function k calls
+// above target in the stacks (its k-hop callers), the samples in which it calls target.
+// A negative k selects the functions -k calls below target instead, its callees.
+// Inlined calls count as calls, as in AnalyzeEdges.
+//
+// Every occurrence of target in a stack is considered, e.g. the 1-hop callers of f in
+// the stack main -> f -> g -> f are main and g. A sample counts at most once per
+// function, or per path if withPaths is set, unless opts.PerFrameCum is set.
+// With withPaths, the functions are further split by the path from caller to callee,
+// e.g. "which allocation path costs the most": AnalyzeKHop(data, opts, "runtime.mallocgc", 3, true).
+//
+// The result is sorted by cum descending. Percent is relative to the cum of target.
+// It returns an error if target is not found in the profile.
+func AnalyzeKHop(data []byte, opts Options, target string, k int, withPaths bool) ([]*models.KHopStat, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	valueIdx, err := selectSampleIndex(p, opts.SampleIndex)
+	if err != nil {
+		return nil, err
+	}
+	scale, unit, err := valueScale(p.SampleType[valueIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	statMap := make(map[string]*models.KHopStat)
+	// Keys whose cum already includes the current sample
+	seen := make(map[string]bool)
+	found := false
+	targetCum := models.Value{Unit: unit}
+	for _, sample := range p.Sample {
+		if len(sample.Value) <= valueIdx {
+			continue
+		}
+		delta := models.Value{Amount: sample.Value[valueIdx] * scale, Unit: unit}
+
+		clear(seen)
+		targetSeen := false
+		frames := stackFrames(sample)
+		for i, f := range frames {
+			if !f.named() || f.line.Function.Name != target {
+				continue
+			}
+			if !targetSeen || opts.PerFrameCum {
+				targetCum = targetCum.Add(delta)
+			}
+			found = true
+			targetSeen = true
+
+			// frames are ordered from the leaf, the k-hop caller is at i+k
+			j := i + k
+			if j < 0 || j >= len(frames) {
+				continue
+			}
+			lo, hi := min(i, j), max(i, j)
+			path := make([]string, 0, hi-lo+1)
+			complete := true
+			for n := hi; n >= lo; n-- {
+				if !frames[n].named() {
+					complete = false
+					break
+				}
+				path = append(path, frames[n].line.Function.Name)
+			}
+			if !complete {
+				continue
+			}
+
+			name := frames[j].line.Function.Name
+			key := name
+			if withPaths {
+				key = strings.Join(path, "\x00")
+			}
+			if seen[key] && !opts.PerFrameCum {
+				continue
+			}
+			seen[key] = true
+
+			// Only the innermost frame of the path gets flat, if it is the leaf
+			flat := models.Value{Unit: unit}
+			if lo == 0 {
+				flat = delta
+			}
+			stat, exists := statMap[key]
+			if !exists {
+				stat = &models.KHopStat{FunctionName: name, Flat: models.Value{Unit: unit}, Cum: models.Value{Unit: unit}}
+				if withPaths {
+					stat.Path = path
+				}
+				statMap[key] = stat
+			}
+			stat.Flat = stat.Flat.Add(flat)
+			stat.Cum = stat.Cum.Add(delta)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("function '%s' not found in the profile", target)
+	}
+
+	result := make([]*models.KHopStat, 0, len(statMap))
+	for _, stat := range statMap {
+		if targetCum.Amount != 0 {
+			stat.Percent = float64(stat.Cum.Amount) / float64(targetCum.Amount) * 100
+		}
+		result = append(result, stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Cum.Amount != b.Cum.Amount {
+			return a.Cum.Amount > b.Cum.Amount
+		}
+		if a.FunctionName != b.FunctionName {
+			return a.FunctionName < b.FunctionName
+		}
+		return strings.Join(a.Path, "\x00") < strings.Join(b.Path, "\x00")
+	})
+
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/imexporter"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeKHop(t *testing.T) {
+	data := syntheticProfile(t, []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}}, []syntheticSample{
+		{stack: []string{"runtime.mallocgc", "runtime.newobject", "main.parse", "main.main"}, values: []int64{10}},
+		{stack: []string{"runtime.mallocgc", "runtime.makeslice", "main.parse", "main.main"}, values: []int64{20}},
+		{stack: []string{"runtime.mallocgc", "runtime.newobject", "main.walk", "main.main"}, values: []int64{40}},
+		// main.walk recurses
+		{stack: []string{"runtime.mallocgc", "runtime.newobject", "main.walk", "main.walk", "main.main"}, values: []int64{80}},
+		{stack: []string{"main.parse", "main.main"}, values: []int64{5}},
+	})
+	type row struct {
+		function  string
+		path      string
+		flat, cum int64
+		percent   string
+	}
+	rows := func(target string, k int, withPaths bool, opts Options) []row {
+		stats, err := AnalyzeKHop(data, opts, target, k, withPaths)
+		assert.Nil(t, err)
+		var result []row
+		for _, s := range stats {
+			result = append(result, row{s.FunctionName, strings.Join(s.Path, " -> "), s.Flat.Amount, s.Cum.Amount, fmt.Sprintf("%.2f", s.Percent)})
+		}
+		return result
+	}
+
+	assert.Equal(t, []row{
+		{"runtime.newobject", "", 130, 130, "86.67"},
+		{"runtime.makeslice", "", 20, 20, "13.33"},
+	}, rows("runtime.mallocgc", 1, false, Options{}))
+	assert.Equal(t, []row{
+		{"main.walk", "", 120, 120, "80.00"},
+		{"main.parse", "", 30, 30, "20.00"},
+	}, rows("runtime.mallocgc", 2, false, Options{}))
+	// Which allocation path costs the most
+	assert.Equal(t, []row{
+		{"main.walk", "main.walk -> main.walk -> runtime.newobject -> runtime.mallocgc", 80, 80, "53.33"},
+		{"main.main", "main.main -> main.walk -> runtime.newobject -> runtime.mallocgc", 40, 40, "26.67"},
+		{"main.main", "main.main -> main.parse -> runtime.makeslice -> runtime.mallocgc", 20, 20, "13.33"},
+		{"main.main", "main.main -> main.parse -> runtime.newobject -> runtime.mallocgc", 10, 10, "6.67"},
+	}, rows("runtime.mallocgc", 3, true, Options{}))
+
+	// Every occurrence of a recursive target counts, each sample once per function
+	assert.Equal(t, []row{
+		{"main.main", "", 0, 120, "100.00"},
+		{"main.walk", "", 0, 80, "66.67"},
+	}, rows("main.walk", 1, false, Options{}))
+	assert.Equal(t, []row{
+		{"runtime.newobject", "", 0, 120, "100.00"},
+		{"main.walk", "", 0, 80, "66.67"},
+	}, rows("main.walk", -1, false, Options{}))
+	assert.Equal(t, []row{
+		{"main.main", "", 0, 120, "60.00"},
+		{"main.walk", "", 0, 80, "40.00"},
+	}, rows("main.walk", 1, false, Options{PerFrameCum: true}))
+
+	// Callees get flat if they are the leaf
+	assert.Equal(t, []row{
+		{"runtime.makeslice", "main.parse -> runtime.makeslice", 0, 20, "57.14"},
+		{"runtime.newobject", "main.parse -> runtime.newobject", 0, 10, "28.57"},
+	}, rows("main.parse", -1, true, Options{}))
+	assert.Equal(t, []row{
+		{"runtime.newobject", "", 0, 80, "51.61"},
+		{"runtime.mallocgc", "", 70, 70, "45.16"},
+	}, rows("main.main", -3, false, Options{}))
+
+	_, err := AnalyzeKHop(data, Options{}, "main.missing", 1, false)
+	assert.ErrorContains(t, err, "not found in the profile")
+
+	stats, err := AnalyzeKHop(data, Options{}, "main.parse", -1, true)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.Nil(t, imexporter.New().ExportKHop(&buf, stats, "", true))
+	assert.Equal(t, "function,flat,cum,unit,percent,path\n"+
+		"runtime.makeslice,0ns,20ns,nanoseconds,57.14,main.parse;runtime.makeslice\n"+
+		"runtime.newobject,0ns,10ns,nanoseconds,28.57,main.parse;runtime.newobject\n", buf.String())
+
+	// The file based variant agrees with the unweighted name set
+	path := common.AbsPathFromRoot("test/go_parser/default.out")
+	names, err := GetCallerKNameSet(path, "runtime.mallocgc", 1, "")
+	assert.Nil(t, err)
+	callerStats, err := GetCallerKStats(path, "runtime.mallocgc", 1, false, Options{})
+	assert.Nil(t, err)
+	var statNames []string
+	for _, s := range callerStats {
+		statNames = append(statNames, s.FunctionName)
+		assert.LessOrEqual(t, s.Percent, 100.0)
+	}
+	assert.ElementsMatch(t, names, statNames)
+}
@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/google/pprof/profile"
+)
+
+// AnalyzeByLabel is like AnalyzeWithOptions, but analyzes the samples of each value of
+// the label key separately, e.g. the samples recorded under pprof.Do with
+// pprof.Labels("request", "login") and ("request", "search"). The lines and function
+// stats of each value have their Label set to it and are sorted by cumulative value
+// descending, functions of equal cum by name. The values are in lexical order.
+// Samples without the label are grouped under the empty value.
+func AnalyzeByLabel(data []byte, opts Options, key string) ([]*models.SourceLine, []*models.FunctionStat, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups := make(map[string][]*profile.Sample)
+	for _, sample := range p.Sample {
+		value := sampleLabel(sample, key)
+		groups[value] = append(groups[value], sample)
+	}
+	values := make([]string, 0, len(groups))
+	for value := range groups {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	var lines []*models.SourceLine
+	var funcs []*models.FunctionStat
+	for _, value := range values {
+		p.Sample = groups[value]
+		groupLines, funcStats, err := analyzeProfile(p, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, line := range groupLines {
+			line.Label = value
+		}
+		lines = append(lines, groupLines...)
+
+		groupFuncs := make([]*models.FunctionStat, 0, len(funcStats))
+		for _, fs := range funcStats {
+			fs.Label = value
+			groupFuncs = append(groupFuncs, fs)
+		}
+		sort.Slice(groupFuncs, func(i, j int) bool {
+			if groupFuncs[i].Cum.Amount != groupFuncs[j].Cum.Amount {
+				return groupFuncs[i].Cum.Amount > groupFuncs[j].Cum.Amount
+			}
+			return groupFuncs[i].FunctionName < groupFuncs[j].FunctionName
+		})
+		funcs = append(funcs, groupFuncs...)
+	}
+	return lines, funcs, nil
+}
+
+// sampleLabel returns the value of the label key of sample, string or numeric.
+// Several values are joined with ",".
+func sampleLabel(sample *profile.Sample, key string) string {
+	if values, ok := sample.Label[key]; ok {
+		return strings.Join(values, ",")
+	}
+	values := make([]string, len(sample.NumLabel[key]))
+	for i, n := range sample.NumLabel[key] {
+		values[i] = strconv.FormatInt(n, 10)
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/Lslightly/pprof2csv/imexporter"
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeByLabel(t *testing.T) {
+	data := syntheticProfile(t, []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}}, []syntheticSample{
+		{stack: []string{"main.handle", "main.serve"}, values: []int64{10}, labels: map[string][]string{"request": {"login"}}},
+		{stack: []string{"main.handle", "main.serve"}, values: []int64{20}, labels: map[string][]string{"request": {"search"}}},
+		{stack: []string{"main.serve"}, values: []int64{5}, labels: map[string][]string{"request": {"search"}}},
+		{stack: []string{"main.gc"}, values: []int64{40}},
+	})
+	lines, funcs, err := AnalyzeByLabel(data, Options{}, "request")
+	assert.Nil(t, err)
+
+	type row struct {
+		label, function string
+		flat, cum       int64
+	}
+	var funcRows []row
+	for _, fs := range funcs {
+		funcRows = append(funcRows, row{fs.Label, fs.FunctionName, fs.Flat.Amount, fs.Cum.Amount})
+	}
+	assert.Equal(t, []row{
+		{"", "main.gc", 40, 40},
+		{"login", "main.handle", 10, 10},
+		{"login", "main.serve", 0, 10},
+		{"search", "main.serve", 5, 25},
+		{"search", "main.handle", 20, 20},
+	}, funcRows)
+	assert.Len(t, lines, 5)
+	assert.Equal(t, "search", lines[3].Label)
+	assert.Equal(t, "main.serve", lines[3].FunctionName)
+
+	var buf bytes.Buffer
+	assert.Nil(t, imexporter.New().ExportFunctionsByLabel(&buf, funcs, ""))
+	assert.True(t, strings.HasPrefix(buf.String(), "function,flat,cum,unit,label\nmain.gc,40ns,40ns,nanoseconds,\nmain.handle,10ns,10ns,nanoseconds,login\n"))
+
+	buf.Reset()
+	assert.Nil(t, imexporter.New().ExportByLabel(&buf, lines, ""))
+	assert.Contains(t, buf.String(), "file,line,function,flat,cum,unit,inlined,inlined_into,label\n")
+	assert.Equal(t, lines, imexporter.Import(&buf))
+
+	// sum% restarts at each label group
+	exporter := imexporter.New()
+	exporter.Totals, err = PercentTotals(data, Options{}, false)
+	assert.Nil(t, err)
+	buf.Reset()
+	assert.Nil(t, exporter.ExportFunctionsByLabel(&buf, funcs, ""))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.Nil(t, err)
+	var sums []string
+	for _, row := range rows[1:] {
+		sums = append(sums, row[7])
+	}
+	assert.Equal(t, []string{"53.33", "13.33", "13.33", "6.67", "33.33"}, sums)
+
+	// Without labels, functions of equal cum are ordered by name as well
+	buf.Reset()
+	assert.Nil(t, imexporter.New().ExportFunctions(&buf, map[string]*models.FunctionStat{
+		"main.b": {FunctionName: "main.b", Cum: models.TimeValue(5)},
+		"main.c": {FunctionName: "main.c", Cum: models.TimeValue(7)},
+		"main.a": {FunctionName: "main.a", Cum: models.TimeValue(5)},
+	}, ""))
+	assert.Equal(t, "function,flat,cum,unit\nmain.c,0ns,7ns,nanoseconds\nmain.a,0ns,5ns,nanoseconds\nmain.b,0ns,5ns,nanoseconds\n", buf.String())
+}
@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/google/pprof/profile"
+)
+
+// ContentionSampleTypes are the sample types of block and mutex profiles
+// written by go test -blockprofile and -mutexprofile.
+var ContentionSampleTypes = []string{"contentions", "delay"}
+
+// IsContentionProfile reports whether the profile data is a block or mutex profile,
+// i.e. it has all of ContentionSampleTypes.
+func IsContentionProfile(data []byte) (bool, error) {
+	p, err := profile.ParseData(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse profile data: %w", err)
+	}
+	for _, st := range ContentionSampleTypes {
+		if _, err := p.SampleIndexByName(st); err != nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// AnalyzeSampleTypes parses the pprof profile data and aggregates every sample type
+// selected by sampleIndexes (names or indexes, see Options.SampleIndex) per source line.
+// If sampleIndexes is empty, all sample types of the profile are analyzed.
+// opts.SampleIndex is ignored. The samples are filtered by opts as in AnalyzeWithOptions.
+// It returns:
+//   - sampleTypes: the names of the selected sample types, in the order of sampleIndexes
+//   - lines: per-source-line stats, Flat[i] and Cum[i] belong to sampleTypes[i].
+//     They are sorted by the cumulative value of the profile's default sample type
+//     descending, or of the first selected sample type if the default one is not selected.
+func AnalyzeSampleTypes(data []byte, opts Options, sampleIndexes []string) ([]string, []*models.MultiSourceLine, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defaultIdx, err := selectSampleIndex(p, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	sortIdx := 0
+
+	if len(sampleIndexes) == 0 {
+		for i := range p.SampleType {
+			sampleIndexes = append(sampleIndexes, strconv.Itoa(i))
+		}
+	}
+
+	sampleTypes := make([]string, len(sampleIndexes))
+	lineMap := make(map[string]*models.MultiSourceLine)
+	for i, si := range sampleIndexes {
+		valueIdx, err := selectSampleIndex(p, si)
+		if err != nil {
+			return nil, nil, err
+		}
+		sampleTypes[i] = p.SampleType[valueIdx].Type
+		if valueIdx == defaultIdx {
+			sortIdx = i
+		}
+
+		o := opts
+		o.SampleIndex = si
+		lines, _, err := analyzeProfile(p, o)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Every sample type yields the same set of source lines, as lines with zero
+		// value are kept. Join them by file, line and function.
+		for _, line := range lines {
+			key := fmt.Sprintf("%s:%d:%s", line.Filename, line.LineNumber, line.FunctionName)
+			ml, exists := lineMap[key]
+			if !exists {
+				ml = &models.MultiSourceLine{
+					Filename:     line.Filename,
+					LineNumber:   line.LineNumber,
+					FunctionName: line.FunctionName,
+					Cum:          make([]models.Value, len(sampleIndexes)),
+					Flat:         make([]models.Value, len(sampleIndexes)),
+					InlinedInto:  line.InlinedInto,
+				}
+				lineMap[key] = ml
+			}
+			ml.Cum[i] = line.Cum
+			ml.Flat[i] = line.Flat
+		}
+	}
+
+	result := make([]*models.MultiSourceLine, 0, len(lineMap))
+	for _, line := range lineMap {
+		result = append(result, line)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Cum[sortIdx].Amount > result[j].Cum[sortIdx].Amount
+	})
+
+	return sampleTypes, result, nil
+}
@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"sort"
+
+	"github.com/Lslightly/pprof2csv/models"
+)
+
+// AnalyzeRootFunctions parses the pprof profile data and aggregates the sample type
+// selected by opts per root function, i.e. the outermost frame of each sample.
+// For goroutine profiles (/debug/pprof/goroutine) this is the number of goroutines
+// started from each go statement.
+// The result is sorted by value descending.
+func AnalyzeRootFunctions(data []byte, opts Options) ([]*models.RootFunctionStat, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	valueIdx, err := selectSampleIndex(p, opts.SampleIndex)
+	if err != nil {
+		return nil, err
+	}
+	scale, unit, err := valueScale(p.SampleType[valueIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	rootMap := make(map[string]*models.RootFunctionStat)
+	for _, sample := range p.Sample {
+		if len(sample.Location) == 0 || len(sample.Value) <= valueIdx {
+			continue
+		}
+
+		// The root is the outermost line of the outermost location,
+		// as inlined lines are ordered from callee to caller
+		rootLoc := sample.Location[len(sample.Location)-1]
+		if len(rootLoc.Line) == 0 {
+			continue
+		}
+		root := rootLoc.Line[len(rootLoc.Line)-1].Function
+		if root == nil || root.Name == "" {
+			continue
+		}
+
+		delta := models.Value{Amount: sample.Value[valueIdx] * scale, Unit: unit}
+		if rs, exists := rootMap[root.Name]; exists {
+			rs.Value = rs.Value.Add(delta)
+		} else {
+			rootMap[root.Name] = &models.RootFunctionStat{
+				FunctionName: root.Name,
+				Filename:     root.Filename,
+				StartLine:    int(root.StartLine),
+				Value:        delta,
+			}
+		}
+	}
+
+	result := make([]*models.RootFunctionStat, 0, len(rootMap))
+	for _, rs := range rootMap {
+		result = append(result, rs)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Value.Amount > result[j].Value.Amount
+	})
+
+	return result, nil
+}
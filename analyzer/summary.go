@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"time"
+
+	"github.com/Lslightly/pprof2csv/models"
+)
+
+// SummarizeProfile returns the total value of the sample type selected by opts.SampleIndex,
+// the number of samples, the duration of the profile and, for CPU profiles, the CPU time
+// of the samples. Only Path and the path-derived dimensions of the summary are left empty.
+func SummarizeProfile(data []byte, opts Options) (*models.ProfileSummary, error) {
+	p, err := parseProfile(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	valueIdx, err := selectSampleIndex(p, opts.SampleIndex)
+	if err != nil {
+		return nil, err
+	}
+	scale, unit, err := valueScale(p.SampleType[valueIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	// CPU profiles aggregate identical stacks, so samples are counted by the samples sample type
+	countIdx := -1
+	for i, st := range p.SampleType {
+		if st.Type == "samples" {
+			countIdx = i
+		}
+	}
+
+	summary := &models.ProfileSummary{
+		SampleType: p.SampleType[valueIdx].Type,
+		Total:      models.Value{Unit: unit},
+		Duration:   time.Duration(p.DurationNanos),
+	}
+	for _, sample := range p.Sample {
+		if len(sample.Value) > valueIdx {
+			summary.Total.Amount += sample.Value[valueIdx] * scale
+		}
+		if countIdx < 0 {
+			summary.Samples++
+		} else if len(sample.Value) > countIdx {
+			summary.Samples += sample.Value[countIdx]
+		}
+	}
+
+	// CPU time is the value of time sample types, otherwise one period per sample
+	if pt := p.PeriodType; pt != nil && pt.Type == "cpu" && pt.Unit == "nanoseconds" {
+		if unit == models.UnitNanoseconds {
+			summary.CPUTime = summary.Total.Duration()
+		} else if countIdx >= 0 {
+			summary.CPUTime = time.Duration(summary.Samples * p.Period)
+		}
+	}
+	return summary, nil
+}
+
+// PercentTotals returns the summary whose Total is the denominator of percentages of
+// the values analyzed with opts, see imexporter.CSVExporter.Totals. The total is taken
+// over all samples, like go tool pprof, unless relative is set, then over the samples
+// selected by opts.ShowFrom and opts.Filter.
+func PercentTotals(data []byte, opts Options, relative bool) (*models.ProfileSummary, error) {
+	if !relative {
+		opts.ShowFrom = ""
+		opts.Filter = Filter{}
+	}
+	return SummarizeProfile(data, opts)
+}
+
+// SampleTypeTotals returns the PercentTotals of each of sampleTypes, e.g. of the
+// columns of AnalyzeSampleTypes, see imexporter.CSVExporter.MultiTotals.
+func SampleTypeTotals(data []byte, opts Options, relative bool, sampleTypes []string) ([]*models.ProfileSummary, error) {
+	totals := make([]*models.ProfileSummary, len(sampleTypes))
+	for i, st := range sampleTypes {
+		opts.SampleIndex = st
+		total, err := PercentTotals(data, opts, relative)
+		if err != nil {
+			return nil, err
+		}
+		totals[i] = total
+	}
+	return totals, nil
+}
@@ -0,0 +1,98 @@
+package analyzer
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// syntheticSample is a sample of a synthetic profile.
+// stack lists its locations from leaf to root, values has one value per sample type,
+// labels are its string labels.
+//
+// A location is written as its frames from the innermost inlined function outwards,
+// separated by ";". A frame is a function name, at line 10 of synthetic.go, or
+// "function file:line", e.g. "main.alloc main.go:5;main.walk main.go:20" for main.alloc
+// inlined into main.walk. A location written as an address, e.g. 0x1000, has no line
+// information. Locations written the same, and functions of the same name and file,
+// are shared.
+type syntheticSample struct {
+	stack  []string
+	values []int64
+	labels map[string][]string
+}
+
+// syntheticProfile builds the serialized profile of samples.
+func syntheticProfile(t *testing.T, sampleTypes []*profile.ValueType, samples []syntheticSample) []byte {
+	p := &profile.Profile{
+		SampleType: sampleTypes,
+	}
+	funcs := make(map[string]*profile.Function)
+	function := func(name, filename string) *profile.Function {
+		key := name + " " + filename
+		fn, ok := funcs[key]
+		if !ok {
+			fn = &profile.Function{
+				ID:       uint64(len(p.Function) + 1),
+				Name:     name,
+				Filename: filename,
+			}
+			p.Function = append(p.Function, fn)
+			funcs[key] = fn
+		}
+		return fn
+	}
+
+	locs := make(map[string]*profile.Location)
+	for _, s := range samples {
+		sample := &profile.Sample{Value: s.values, Label: s.labels}
+		for _, l := range s.stack {
+			loc, ok := locs[l]
+			if !ok {
+				loc = &profile.Location{ID: uint64(len(p.Location) + 1)}
+				if addr, ok := strings.CutPrefix(l, "0x"); ok {
+					address, err := strconv.ParseUint(addr, 16, 64)
+					if err != nil {
+						t.Fatalf("invalid synthetic location %q: %v", l, err)
+					}
+					loc.Address = address
+				} else {
+					for _, frame := range strings.Split(l, ";") {
+						loc.Line = append(loc.Line, syntheticLine(t, frame, function))
+					}
+				}
+				p.Location = append(p.Location, loc)
+				locs[l] = loc
+			}
+			sample.Location = append(sample.Location, loc)
+		}
+		p.Sample = append(p.Sample, sample)
+	}
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("failed to write synthetic profile: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// syntheticLine parses a frame of a synthetic location, see syntheticSample. function
+// returns the function of a name and file.
+func syntheticLine(t *testing.T, frame string, function func(name, filename string) *profile.Function) profile.Line {
+	name, pos, hasPos := strings.Cut(frame, " ")
+	if !hasPos {
+		return profile.Line{Function: function(name, "synthetic.go"), Line: 10}
+	}
+	i := strings.LastIndex(pos, ":")
+	if i < 0 {
+		t.Fatalf("invalid synthetic frame %q, want function file:line", frame)
+	}
+	line, err := strconv.ParseInt(pos[i+1:], 10, 64)
+	if err != nil {
+		t.Fatalf("invalid synthetic frame %q: %v", frame, err)
+	}
+	return profile.Line{Function: function(name, pos[:i]), Line: line}
+}
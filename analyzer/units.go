@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/google/pprof/profile"
+)
+
+// UnknownUnitError is returned when the unit of the analyzed sample type is
+// neither a time, a memory nor a count unit.
+type UnknownUnitError struct {
+	SampleType string
+	Unit       string
+}
+
+func (e *UnknownUnitError) Error() string {
+	return fmt.Sprintf("unknown unit %q of sample type %q", e.Unit, e.SampleType)
+}
+
+// convTimeUnit returns the duration of one unit of the time unit s, or false
+// if s is not a time unit. Both long and short unit names are accepted.
+func convTimeUnit(s string) (time.Duration, bool) {
+	switch strings.ToLower(s) {
+	case "nanoseconds", "nanosecond", "ns":
+		return time.Nanosecond, true
+	case "microseconds", "microsecond", "us", "µs":
+		return time.Microsecond, true
+	case "milliseconds", "millisecond", "ms":
+		return time.Millisecond, true
+	case "seconds", "second", "sec", "s":
+		return time.Second, true
+	case "minutes", "minute", "m":
+		return time.Minute, true
+	case "hours", "hour", "h":
+		return time.Hour, true
+	default:
+		return 0, false
+	}
+}
+
+// convMemoryUnit returns the number of bytes of one unit of the memory unit s,
+// or false if s is not a memory unit. Like pprof, a kilobyte is 1024 bytes.
+func convMemoryUnit(s string) (int64, bool) {
+	switch strings.ToLower(s) {
+	case "bytes", "byte", "b":
+		return 1, true
+	case "kilobytes", "kilobyte", "kb":
+		return 1 << 10, true
+	case "megabytes", "megabyte", "mb":
+		return 1 << 20, true
+	case "gigabytes", "gigabyte", "gb":
+		return 1 << 30, true
+	case "terabytes", "terabyte", "tb":
+		return 1 << 40, true
+	default:
+		return 0, false
+	}
+}
+
+// countUnits are units of values that are counted and kept as they are.
+// Besides "count" used by Go profiles, they include the hardware event units
+// of profiles converted with perf_to_profile.
+var countUnits = map[string]bool{
+	"count":        true,
+	"samples":      true,
+	"objects":      true,
+	"events":       true,
+	"cycles":       true,
+	"instructions": true,
+}
+
+// valueScale returns the factor applied to raw sample values of st and the
+// unit of the scaled values. Time values are stored as nanoseconds, memory
+// values as bytes, count values (count, cycles, ...) are kept as they are.
+// It returns an *UnknownUnitError for any other unit.
+func valueScale(st *profile.ValueType) (scale int64, scaledUnit models.Unit, err error) {
+	if d, ok := convTimeUnit(st.Unit); ok {
+		return int64(d), models.UnitNanoseconds, nil
+	}
+	if b, ok := convMemoryUnit(st.Unit); ok {
+		return b, models.UnitBytes, nil
+	}
+	if countUnits[st.Unit] {
+		return 1, models.Unit(st.Unit), nil
+	}
+	return 0, "", &UnknownUnitError{SampleType: st.Type, Unit: st.Unit}
+}
@@ -0,0 +1,38 @@
+package analyzer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Lslightly/pprof2csv/models"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueUnits(t *testing.T) {
+	samples := []syntheticSample{
+		{stack: []string{"main.leaf", "main.main"}, values: []int64{3}},
+	}
+	testCases := []struct {
+		unit string
+		want models.Value
+	}{
+		{unit: "microseconds", want: models.TimeValue(3 * time.Microsecond)},
+		{unit: "seconds", want: models.TimeValue(3 * time.Second)},
+		{unit: "kilobytes", want: models.Value{Amount: 3 << 10, Unit: models.UnitBytes}},
+		{unit: "cycles", want: models.Value{Amount: 3, Unit: "cycles"}},
+	}
+	for _, tc := range testCases {
+		data := syntheticProfile(t, []*profile.ValueType{{Type: "value", Unit: tc.unit}}, samples)
+		_, funcStats, err := AnalyzeWithOptions(data, Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, funcStats["main.leaf"].Flat, "unit %s", tc.unit)
+	}
+
+	data := syntheticProfile(t, []*profile.ValueType{{Type: "value", Unit: "furlongs"}}, samples)
+	_, _, err := AnalyzeWithOptions(data, Options{})
+	var unitErr *UnknownUnitError
+	assert.True(t, errors.As(err, &unitErr))
+	assert.Equal(t, "furlongs", unitErr.Unit)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Lslightly/pprof2csv/analyzer"
+	"github.com/Lslightly/pprof2csv/imexporter"
+	"github.com/Lslightly/pprof2csv/loader"
+	"github.com/Lslightly/pprof2csv/models"
+)
+
+// convertDir converts every profile in the directory tree root to a sibling CSV,
+// e.g. BenchmarkPushPop/cpu-100-default.out to BenchmarkPushPop/cpu-100-default.csv,
+// or to a graph file with the graph granularity, and writes the summaries of the profiles to the index file.
+func convertDir(root string) error {
+	paths, err := loader.FindProfiles(root)
+	if err != nil {
+		return err
+	}
+
+	// The index summarizes the whole profiles, -show_from and the filters only apply to
+	// their CSVs
+	indexOpts := analyzerOptions()
+	indexOpts.ShowFrom, indexOpts.Filter = "", analyzer.Filter{}
+
+	var summaries []*models.ProfileSummary
+	for _, path := range paths {
+		data, err := loader.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if loader.DetectEncoding(data) == loader.EncodingUnknown {
+			fmt.Fprintf(os.Stderr, "Skipping %s: not a profile\n", path)
+			continue
+		}
+
+		summary, err := analyzer.SummarizeProfile(data, indexOpts)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		summary.Path, err = filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		summary.Benchmark, summary.Kind, summary.Setting, summary.Variant = pathDimensions(summary.Path)
+		summaries = append(summaries, summary)
+
+		export, err := analyze(data, analyzerOptions())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := exportFile(loader.TrimProfileExt(path)+outputExt(), export); err != nil {
+			return err
+		}
+	}
+
+	indexPath := *outputFile
+	if indexPath == "" {
+		indexPath = filepath.Join(root, "index.csv")
+	}
+	err = exportFile(indexPath, func(w io.Writer) error {
+		return imexporter.New().ExportIndex(w, summaries, *unit)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully converted %d profiles in %s, index written to %s\n", len(summaries), root, indexPath)
+	return nil
+}
+
+// exportFile creates the file path and calls export with it.
+func exportFile(path string, export func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	defer f.Close()
+	if err := export(f); err != nil {
+		return fmt.Errorf("error exporting %s: %v", path, err)
+	}
+	return nil
+}
+
+// pathDimensions derives the dimensions of a profile from its path relative to the root
+// directory, laid out as <Benchmark>/<kind>-<setting>-<variant>.out. The benchmark is
+// the parent directory, dimensions are empty if the file name has fewer parts.
+func pathDimensions(rel string) (benchmark, kind, setting, variant string) {
+	if dir := filepath.Dir(rel); dir != "." {
+		benchmark = filepath.ToSlash(dir)
+	}
+	parts := strings.SplitN(loader.TrimProfileExt(filepath.Base(rel)), "-", 3)
+	if len(parts) == 3 {
+		kind, setting, variant = parts[0], parts[1], parts[2]
+	}
+	return
+}
@@ -0,0 +1,219 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Lslightly/pprof2csv/analyzer"
+	"github.com/Lslightly/pprof2csv/cmd/lines2md/qlib"
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/imexporter"
+	"github.com/Lslightly/pprof2csv/srcpath"
+)
+
+// profileFlags maps profile kinds to the go test flag writing them.
+var profileFlags = map[string]string{
+	"cpu":   "-cpuprofile",
+	"mem":   "-memprofile",
+	"block": "-blockprofile",
+	"mutex": "-mutexprofile",
+}
+
+// Setting is one value of a dimension of the settings matrix, e.g. 100 for GOGC=100.
+type Setting struct {
+	Name  string
+	Env   []string // environment variables, KEY=VALUE
+	Flags []string // go test flags, e.g. -count=5 or -memprofilerate=1
+}
+
+// DefaultSetting is the setting used when a dimension has no settings. It changes nothing.
+var DefaultSetting = Setting{Name: "default"}
+
+// ParseSetting parses a setting written as name:item;item;..., e.g. 100:GOGC=100 or
+// off:GOGC=off;-count=5. Items starting with - are go test flags, the others environment variables.
+func ParseSetting(s string) (Setting, error) {
+	name, items, _ := strings.Cut(s, ":")
+	if name == "" || strings.ContainsAny(name, "-/") {
+		return Setting{}, fmt.Errorf("invalid setting %q: name must be non-empty and contain neither - nor /", s)
+	}
+	setting := Setting{Name: name}
+	for _, item := range strings.Split(items, ";") {
+		switch {
+		case item == "":
+		case strings.HasPrefix(item, "-"):
+			setting.Flags = append(setting.Flags, item)
+		case strings.Contains(item, "="):
+			setting.Env = append(setting.Env, item)
+		default:
+			return Setting{}, fmt.Errorf("invalid setting %q: %q is neither a -flag nor KEY=VALUE", s, item)
+		}
+	}
+	return setting, nil
+}
+
+// Config describes the benchmarks to run and the profiles to collect.
+type Config struct {
+	Pkg      string    // directory of the package to benchmark
+	Bench    string    // regexp selecting the benchmarks, as go test -bench
+	OutDir   string    // profiles are stored as <OutDir>/<Benchmark>/<kind>-<setting>-<variant>.out
+	Kinds    []string  // profile kinds: cpu, mem, block, mutex
+	Settings []Setting // first dimension of the matrix, e.g. GOGC values
+	Variants []Setting // second dimension of the matrix, e.g. code variants selected by env vars
+	Count    int       // go test -count, if positive
+
+	QueryFile string             // lines2md query file, no lines2md output if empty
+	Unit      string             // unit of the CSV and lines2md output
+	Paths     srcpath.Normalizer // normalizes the source paths of the profiles and queries
+}
+
+// Profile is a profile collected by Collect and the outputs produced from it.
+type Profile struct {
+	Benchmark string
+	Kind      string
+	Setting   string
+	Variant   string
+	Path      string // <kind>-<setting>-<variant>.out
+	CSVPath   string // <kind>-<setting>-<variant>.csv
+	MDDir     string // <kind>-<setting>-<variant>/, lines2md output, empty without query file
+}
+
+// ListBenchmarks returns the benchmarks of the package in pkg matching the regexp bench.
+func ListBenchmarks(pkg, bench string) ([]string, error) {
+	cmd, out, err := common.Runcmd(pkg, "go", "test", "-list", bench, ".")
+	if err != nil {
+		return nil, fmt.Errorf("cmd %s run error: %v\n%s", cmd.String(), err, out)
+	}
+	var benchmarks []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "Benchmark") {
+			benchmarks = append(benchmarks, strings.TrimSpace(line))
+		}
+	}
+	if len(benchmarks) == 0 {
+		return nil, fmt.Errorf("no benchmark in %s matches %q", pkg, bench)
+	}
+	return benchmarks, nil
+}
+
+// Collect runs each benchmark once for every profile kind, setting and variant, and
+// converts each profile to CSV and, if cfg.QueryFile is set, to lines2md output.
+// The output of go test is saved as <kind>-<setting>-<variant>.txt.
+func Collect(cfg Config) ([]Profile, error) {
+	for _, kind := range cfg.Kinds {
+		if _, ok := profileFlags[kind]; !ok {
+			return nil, fmt.Errorf("unknown profile kind %q, must be one of: cpu, mem, block, mutex", kind)
+		}
+	}
+	settings, variants := cfg.Settings, cfg.Variants
+	if len(settings) == 0 {
+		settings = []Setting{DefaultSetting}
+	}
+	if len(variants) == 0 {
+		variants = []Setting{DefaultSetting}
+	}
+
+	var querySections []qlib.QuerySection
+	if cfg.QueryFile != "" {
+		var err error
+		querySections, err = qlib.ParseQueryFile(cfg.QueryFile)
+		if err != nil {
+			return nil, err
+		}
+		// Queries are matched against normalized paths, as in lines2md
+		qlib.NormalizePaths(querySections, &cfg.Paths)
+	}
+
+	benchmarks, err := ListBenchmarks(cfg.Pkg, cfg.Bench)
+	if err != nil {
+		return nil, err
+	}
+	binDir, err := os.MkdirTemp("", "collect")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(binDir)
+
+	var profiles []Profile
+	for _, benchmark := range benchmarks {
+		benchDir := filepath.Join(cfg.OutDir, benchmark)
+		if err := os.MkdirAll(benchDir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating output directory: %v", err)
+		}
+		for _, kind := range cfg.Kinds {
+			for _, setting := range settings {
+				for _, variant := range variants {
+					base := filepath.Join(benchDir, fmt.Sprintf("%s-%s-%s", kind, setting.Name, variant.Name))
+					prof := Profile{
+						Benchmark: benchmark,
+						Kind:      kind,
+						Setting:   setting.Name,
+						Variant:   variant.Name,
+						Path:      base + ".out",
+						CSVPath:   base + ".csv",
+					}
+					if err := runBenchmark(cfg, binDir, prof, setting, variant, base+".txt"); err != nil {
+						return nil, err
+					}
+					if err := convert(cfg, querySections, &prof, base); err != nil {
+						return nil, err
+					}
+					profiles = append(profiles, prof)
+				}
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// runBenchmark runs the benchmark of prof with setting and variant, and writes its output to outPath.
+func runBenchmark(cfg Config, binDir string, prof Profile, setting, variant Setting, outPath string) error {
+	profPath, err := filepath.Abs(prof.Path)
+	if err != nil {
+		return err
+	}
+	args := []string{"test", "-run=^$", "-bench", "^" + prof.Benchmark + "$",
+		"-o", filepath.Join(binDir, "bench.test"), profileFlags[prof.Kind], profPath}
+	if cfg.Count > 0 {
+		args = append(args, fmt.Sprintf("-count=%d", cfg.Count))
+	}
+	args = append(args, setting.Flags...)
+	args = append(args, variant.Flags...)
+	args = append(args, ".")
+
+	env := append(slices.Clone(setting.Env), variant.Env...)
+	cmd, out, err := common.RuncmdEnv(cfg.Pkg, env, "go", args...)
+	if writeErr := os.WriteFile(outPath, out, 0644); writeErr != nil {
+		return fmt.Errorf("error writing benchmark output: %v", writeErr)
+	}
+	if err != nil {
+		return fmt.Errorf("cmd %s run error: %v\n%s", cmd.String(), err, out)
+	}
+	return nil
+}
+
+// convert writes the CSV of prof and, if there are query sections, its lines2md output in base/.
+func convert(cfg Config, querySections []qlib.QuerySection, prof *Profile, base string) error {
+	sourceLines, funcStats, err := analyzer.LoadProfileDataWithOptions(prof.Path, analyzer.Options{Paths: cfg.Paths})
+	if err != nil {
+		return fmt.Errorf("%s: %v", prof.Path, err)
+	}
+
+	csvFile, err := os.Create(prof.CSVPath)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	defer csvFile.Close()
+	if err := imexporter.New().Export(csvFile, sourceLines, cfg.Unit); err != nil {
+		return fmt.Errorf("error exporting CSV: %v", err)
+	}
+
+	if len(querySections) == 0 {
+		return nil
+	}
+	prof.MDDir = base
+	matchedResults := qlib.MatchQueries(querySections, sourceLines)
+	return qlib.WriteResults(prof.MDDir, querySections, matchedResults, funcStats, cfg.Unit, false)
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Lslightly/pprof2csv/srcpath"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSetting(t *testing.T) {
+	setting, err := ParseSetting("off:GOGC=off;GODEBUG=gctrace=1,madvdontneed=1;-count=5")
+	assert.Nil(t, err)
+	assert.Equal(t, Setting{
+		Name:  "off",
+		Env:   []string{"GOGC=off", "GODEBUG=gctrace=1,madvdontneed=1"},
+		Flags: []string{"-count=5"},
+	}, setting)
+
+	setting, err = ParseSetting("default")
+	assert.Nil(t, err)
+	assert.Equal(t, Setting{Name: "default"}, setting)
+
+	_, err = ParseSetting("a-b:GOGC=1")
+	assert.NotNil(t, err)
+	_, err = ParseSetting("100:GOGC")
+	assert.NotNil(t, err)
+}
+
+const benchModule = `module bench
+
+go 1.21
+`
+
+const benchSource = `package bench
+
+import "testing"
+
+var sink []byte
+
+func BenchmarkAlloc(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		sink = make([]byte, 64)
+	}
+}
+
+func BenchmarkOther(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+	}
+}
+`
+
+const benchQuery = `bench.BenchmarkAlloc
+bench_test.go:9,sink = make([]byte, 64)
+`
+
+func TestCollect(t *testing.T) {
+	pkg := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(pkg, "go.mod"), []byte(benchModule), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(pkg, "bench_test.go"), []byte(benchSource), 0644))
+	queryFile := filepath.Join(pkg, "query.txt")
+	assert.Nil(t, os.WriteFile(queryFile, []byte(benchQuery), 0644))
+
+	benchmarks, err := ListBenchmarks(pkg, ".")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"BenchmarkAlloc", "BenchmarkOther"}, benchmarks)
+
+	outDir := t.TempDir()
+	profiles, err := Collect(Config{
+		Pkg:      pkg,
+		Bench:    "Alloc",
+		OutDir:   outDir,
+		Kinds:    []string{"mem"},
+		Settings: []Setting{{Name: "1", Flags: []string{"-memprofilerate=1"}}, {Name: "off", Env: []string{"GOGC=off"}}},
+		Count:    1,
+
+		QueryFile: queryFile,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, profiles, 2)
+
+	prof := profiles[0]
+	assert.Equal(t, "BenchmarkAlloc", prof.Benchmark)
+	assert.Equal(t, filepath.Join(outDir, "BenchmarkAlloc", "mem-1-default.out"), prof.Path)
+	assert.Equal(t, filepath.Join(outDir, "BenchmarkAlloc", "mem-off-default.out"), profiles[1].Path)
+	for _, path := range []string{
+		prof.Path,
+		prof.CSVPath,
+		filepath.Join(outDir, "BenchmarkAlloc", "mem-1-default.txt"),
+		filepath.Join(prof.MDDir, "collect.md"),
+		filepath.Join(prof.MDDir, "bench.BenchmarkAlloc.csv"),
+	} {
+		_, err := os.Stat(path)
+		assert.Nil(t, err, path)
+	}
+
+	_, err = Collect(Config{Pkg: pkg, Bench: "Alloc", OutDir: outDir, Kinds: []string{"heap"}})
+	assert.NotNil(t, err)
+}
+
+func TestCollectNormalizedQuery(t *testing.T) {
+	pkg := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(pkg, "go.mod"), []byte(benchModule), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(pkg, "bench_test.go"), []byte(benchSource), 0644))
+	// The query names the file by its absolute path, the profile paths are trimmed
+	queryFile := filepath.Join(pkg, "query.txt")
+	query := "bench.BenchmarkAlloc\n" + filepath.Join(pkg, "bench_test.go") + ":9,sink = make([]byte, 64)\n"
+	assert.Nil(t, os.WriteFile(queryFile, []byte(query), 0644))
+
+	profiles, err := Collect(Config{
+		Pkg:      pkg,
+		Bench:    "Alloc",
+		OutDir:   t.TempDir(),
+		Kinds:    []string{"mem"},
+		Settings: []Setting{{Name: "1", Flags: []string{"-memprofilerate=1"}}},
+		Count:    1,
+
+		QueryFile: queryFile,
+		Paths:     srcpath.Normalizer{TrimPrefixes: []string{pkg + "/"}},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, profiles, 1)
+
+	records := readCSV(t, filepath.Join(profiles[0].MDDir, "bench.BenchmarkAlloc.csv"))
+	assert.Len(t, records, 2)
+	assert.Equal(t, []string{"bench_test.go", "9"}, records[1][:2])
+	assert.NotEqual(t, "-", records[1][3], "query not matched")
+}
+
+func readCSV(t *testing.T, path string) [][]string {
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	assert.Nil(t, err)
+	return records
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Lslightly/pprof2csv/cmd/collect/lib"
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/srcpath"
+)
+
+var (
+	settingFlags common.StringsFlag
+	variantFlags common.StringsFlag
+	pkg          = flag.String("pkg", ".", "Directory of the package to benchmark")
+	bench        = flag.String("bench", "", "Regexp selecting the benchmarks to run, as go test -bench")
+	outputDir    = flag.String("o", ".", "Output directory, profiles are stored as <dir>/<Benchmark>/<kind>-<setting>-<variant>.out")
+	kinds        = flag.String("profiles", "cpu", "Comma-separated profile kinds to collect: cpu, mem, block, mutex")
+	count        = flag.Int("count", 0, "go test -count for every run (default: go test's default)")
+	queryFile    = flag.String("q", "", "lines2md query file, lines2md output is written to <kind>-<setting>-<variant>/ if provided")
+	unit         = flag.String("unit", "", "Unit for output (s, ms, us, ns for time; B, KB, MB, GB for bytes). Empty string uses default format")
+	paths        srcpath.Normalizer
+)
+
+func init() {
+	flag.Var(&settingFlags, "setting", "Setting of the matrix as name:item;item, items are KEY=VALUE env vars or go test -flags, e.g. 100:GOGC=100 or off:GOGC=off. Repeatable")
+	flag.Var(&variantFlags, "variant", "Variant of the matrix, same syntax as -setting, e.g. default: or nopool:MYAPP_NOPOOL=1. Repeatable")
+	srcpath.AddFlags(flag.CommandLine, &paths)
+}
+
+func parseSettings(values []string) ([]lib.Setting, error) {
+	var settings []lib.Setting
+	for _, value := range values {
+		setting, err := lib.ParseSetting(value)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+func main() {
+	flag.Parse()
+	if *bench == "" {
+		fmt.Fprintln(os.Stderr, "Error: -bench is required")
+		fmt.Fprintln(os.Stderr, "Usage: collect -pkg <package dir> -bench <regexp> [-o <dir>] [-setting name:items]... [-variant name:items]... [-q <query.txt>]")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	settings, err := parseSettings(settingFlags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	variants, err := parseSettings(variantFlags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	profiles, err := lib.Collect(lib.Config{
+		Pkg:       *pkg,
+		Bench:     *bench,
+		OutDir:    *outputDir,
+		Kinds:     strings.Split(*kinds, ","),
+		Settings:  settings,
+		Variants:  variants,
+		Count:     *count,
+		QueryFile: *queryFile,
+		Unit:      *unit,
+		Paths:     paths,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, prof := range profiles {
+		fmt.Fprintf(os.Stderr, "Collected %s\n", prof.Path)
+	}
+}
@@ -8,25 +8,39 @@ import (
 
 	"github.com/Lslightly/pprof2csv/analyzer"
 	"github.com/Lslightly/pprof2csv/cmd/lines2md/qlib"
-	"github.com/Lslightly/pprof2csv/models"
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/loader"
+	"github.com/Lslightly/pprof2csv/srcpath"
 )
 
 // CLI flags
 var (
-	inputProfile  = flag.String("i", "", "Input pprof profile file")
+	inputProfiles common.StringsFlag
 	queryFile     = flag.String("q", "", "Query file containing lines to analyze")
 	outputDir     = flag.String("dir", ".", "Output directory for results")
-	showFrom      = flag.String("show_from", "", "Only include samples whose stacktrace contains this function")
-	unit          = flag.String("unit", "", "Time unit for output (s, ms, us, ns). Empty string uses default format")
+	showFrom      = flag.String("show_from", "", "Only include samples whose stacktrace contains this function, an alias of -focus '^function$'")
+	unit          = flag.String("unit", "", "Unit for output (s, ms, us, ns for time; B, KB, MB, GB for bytes). Empty string uses default format")
 	funcStatInCSV = flag.Bool("csv-funcstat", false, "Print flat and cum of query function in csv")
+	perFrameCum   = flag.Bool("per_frame_cum", false, "Add a sample to cum once per frame, as before, instead of once per line and function. Cum of recursive functions can then exceed the total")
+	binary        = flag.String("bin", "", "Binary the profile was collected from, locations without line information are symbolized against it")
+	sampleIndex   = flag.String("sample_index", "", "Sample type to analyze, by name (e.g. cpu, alloc_space, delay) or index. Empty uses the profile default")
+)
+
+var (
+	paths  srcpath.Normalizer
+	filter analyzer.Filter
 )
 
 func init() {
+	srcpath.AddFlags(flag.CommandLine, &paths)
+	analyzer.AddFilterFlags(flag.CommandLine, &filter)
+	flag.Var(&inputProfiles, "i", "Input pprof profile file, - for stdin or an http(s) /debug/pprof URL. Repeat the flag or use a glob pattern to merge several profiles")
+	loader.AddFetchFlags(flag.CommandLine, &loader.DefaultFetchOptions)
 	flag.Parse()
 }
 
 func validateFlags() error {
-	if *inputProfile == "" {
+	if len(inputProfiles) == 0 {
 		return fmt.Errorf("input file is required\nUsage: lines2md -i <profile.pprof> -q <query.txt> [-dir <output_dir>]")
 	}
 	if *queryFile == "" {
@@ -35,25 +49,6 @@ func validateFlags() error {
 	return nil
 }
 
-func createOutputDirectory() error {
-	if err := os.MkdirAll(*outputDir, 0755); err != nil {
-		return fmt.Errorf("error creating output directory: %v", err)
-	}
-	return nil
-}
-
-func writeCollectMD(markdownContent string) error {
-	collectFile := filepath.Join(*outputDir, "collect.md")
-	collect, err := os.Create(collectFile)
-	if err != nil {
-		return fmt.Errorf("error creating collect.md: %v", err)
-	}
-	defer collect.Close()
-
-	fmt.Fprint(collect, markdownContent)
-	return nil
-}
-
 // Removed convertCSVRowsToSourceLines as it's no longer needed
 
 func main() {
@@ -64,14 +59,10 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create output directory
-	if err := createOutputDirectory(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+	loader.DefaultFetchOptions.DefaultSavePath(filepath.Join(*outputDir, "profile"))
 
 	// Load and analyze profile data (both per-line and per-function stats)
-	allLines, funcStats, err := analyzer.LoadProfileDataWithFunctionStats(*inputProfile, *showFrom)
+	allLines, funcStats, err := analyzer.LoadProfilesWithOptions(inputProfiles, analyzer.Options{ShowFrom: *showFrom, SampleIndex: *sampleIndex, Binary: *binary, Paths: paths, PerFrameCum: *perFrameCum, Filter: filter})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -84,33 +75,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Find matching lines and calculate cumulative values
+	// Find matching lines and calculate cumulative values, with paths normalized as the profile's
+	qlib.NormalizePaths(querySections, &paths)
 	matchedResults := qlib.MatchQueries(querySections, allLines)
 
-	// Generate and write CSV files for each function
-	exportMatchedResults(querySections, matchedResults, funcStats, *unit)
-}
-
-func exportMatchedResults(querySections []qlib.QuerySection, matchedResults map[string]*models.SourceLine, funcStats map[string]*models.FunctionStat, unit string) {
-	for _, section := range querySections {
-		if len(section.Queries) == 0 {
-			continue
-		}
-
-		var funcStat *models.FunctionStat
-		if *funcStatInCSV {
-			funcStat = funcStats[section.FunctionName]
-		}
-
-		if err := section.WriteFunctionCSV(*outputDir, matchedResults, funcStat, unit); err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-		}
-	}
-
-	// Generate and write markdown content (includes per-function flat/cum summary)
-	markdownContent := qlib.GenerateMarkdownContent(querySections, matchedResults, funcStats, unit)
-	if err := writeCollectMD(markdownContent); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+	// Generate and write CSV files for each function and collect.md
+	if err := qlib.WriteResults(*outputDir, querySections, matchedResults, funcStats, *unit, *funcStatInCSV); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
@@ -9,10 +9,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/Lslightly/pprof2csv/common"
 	"github.com/Lslightly/pprof2csv/models"
+	"github.com/Lslightly/pprof2csv/srcpath"
 )
 
 // QueryResult represents query results with code information
@@ -21,8 +21,8 @@ type QueryResult struct {
 	Filename   string
 	LineNumber int
 	Code       string
-	Cum        time.Duration // Cumulative time
-	Flat       time.Duration // Flat time (time spent directly in this function)
+	Cum        models.Value // Cumulative time
+	Flat       models.Value // Flat time (time spent directly in this function)
 }
 
 // QuerySection represents a section in the query file
@@ -118,6 +118,25 @@ func ParseQueryFile(filename string) ([]QuerySection, error) {
 	return result, nil
 }
 
+// NormalizePaths normalizes the file paths of the queries with n, like the paths of the
+// profile are normalized (see analyzer.Options.Paths), so that queries written with
+// the paths of another machine match. Relative paths are usually kept as they are
+// and still match as suffix.
+func NormalizePaths(querySections []QuerySection, n *srcpath.Normalizer) {
+	var files []srcpath.File
+	for _, section := range querySections {
+		for _, query := range section.Queries {
+			files = append(files, srcpath.File{Filename: query.Filename, Function: query.FunctionName})
+		}
+	}
+	normalized := n.NormalizeAll(files)
+	for _, section := range querySections {
+		for i := range section.Queries {
+			section.Queries[i].Filename, normalized = normalized[0], normalized[1:]
+		}
+	}
+}
+
 // findMatchingLines check only filename and line number
 func findMatchingLines(allLines []*models.SourceLine, query Query) []*models.SourceLine {
 	var matchedLines []*models.SourceLine
@@ -141,7 +160,7 @@ func GenerateMarkdownContent(querySections []QuerySection, matchedResults map[st
 		}
 
 		// function-level summary for this section's function name
-		var funcFlat, funcCum time.Duration
+		var funcFlat, funcCum models.Value
 		if stat, ok := funcStats[section.FunctionName]; ok {
 			funcFlat = stat.Flat
 			funcCum = stat.Cum
@@ -150,8 +169,8 @@ func GenerateMarkdownContent(querySections []QuerySection, matchedResults map[st
 			fmt.Fprintf(os.Stderr, "Warning: no function stats found for %s\n", section.FunctionName)
 		}
 
-		funcFlatStr := common.FormatDuration(funcFlat, unit)
-		funcCumStr := common.FormatDuration(funcCum, unit)
+		funcFlatStr := common.FormatHumanValue(funcFlat, unit)
+		funcCumStr := common.FormatHumanValue(funcCum, unit)
 
 		// section title and function summary
 		fmt.Fprintf(&markdownContent, "## %s\n\n", section.FunctionName)
@@ -165,8 +184,8 @@ func GenerateMarkdownContent(querySections []QuerySection, matchedResults map[st
 			key := fmt.Sprintf("%s:%d", query.Filename, query.LineNumber)
 			var cumStr, flatStr string
 			if item, exists := matchedResults[key]; exists {
-				cumStr = common.FormatDuration(item.Cum, unit)
-				flatStr = common.FormatDuration(item.Flat, unit)
+				cumStr = common.FormatHumanValue(item.Cum, unit)
+				flatStr = common.FormatHumanValue(item.Flat, unit)
 			} else {
 				cumStr = "-"
 				flatStr = "-"
@@ -181,6 +200,41 @@ func GenerateMarkdownContent(querySections []QuerySection, matchedResults map[st
 	return markdownContent.String()
 }
 
+// WriteResults writes <function name>.csv for each query section and collect.md into outputDir,
+// which is created if it does not exist. If funcStatInCSV is set, the function flat/cum is
+// appended to each csv.
+func WriteResults(outputDir string, querySections []QuerySection, matchedResults map[string]*models.SourceLine, funcStats map[string]*models.FunctionStat, unit string, funcStatInCSV bool) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	for _, section := range querySections {
+		if len(section.Queries) == 0 {
+			continue
+		}
+
+		var funcStat *models.FunctionStat
+		if funcStatInCSV {
+			funcStat = funcStats[section.FunctionName]
+		}
+
+		if err := section.WriteFunctionCSV(outputDir, matchedResults, funcStat, unit); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+
+	// Generate and write markdown content (includes per-function flat/cum summary)
+	markdownContent := GenerateMarkdownContent(querySections, matchedResults, funcStats, unit)
+	collect, err := os.Create(filepath.Join(outputDir, "collect.md"))
+	if err != nil {
+		return fmt.Errorf("error creating collect.md: %v", err)
+	}
+	defer collect.Close()
+
+	fmt.Fprint(collect, markdownContent)
+	return nil
+}
+
 func (querySection QuerySection) WriteFunctionCSV(outputDir string, matchedResults map[string]*models.SourceLine, funcStat *models.FunctionStat, unit string) error {
 	csvFilename := filepath.Join(outputDir, fmt.Sprintf("%s.csv", querySection.FunctionName))
 	csvFile, err := os.Create(csvFilename)
@@ -208,8 +262,6 @@ func (querySection QuerySection) WriteFunctionCSV(outputDir string, matchedResul
 				Filename:   query.Filename,
 				LineNumber: query.LineNumber,
 				Code:       query.Code,
-				Cum:        0,
-				Flat:       0,
 			})
 		}
 	}
@@ -247,8 +299,8 @@ func exportQueryResults(w io.Writer, results []*QueryResult, unit string) error
 	for _, result := range results {
 		var cumulativeTimeStr, flatTimeStr string
 		if result.Found {
-			cumulativeTimeStr = common.FormatDuration(result.Cum, unit)
-			flatTimeStr = common.FormatDuration(result.Flat, unit)
+			cumulativeTimeStr = common.FormatValue(result.Cum, unit)
+			flatTimeStr = common.FormatValue(result.Flat, unit)
 		} else {
 			cumulativeTimeStr = "-"
 			flatTimeStr = "-"
@@ -289,14 +341,12 @@ func MatchQueries(querySections []QuerySection, allLines []*models.SourceLine) (
 				Filename:     query.Filename,
 				LineNumber:   query.LineNumber,
 				FunctionName: query.FunctionName,
-				Cum:          0,
-				Flat:         0,
 			}
 
 			// Sum up all matching lines
 			for _, sl := range matchedLines {
-				resultLine.Cum += sl.Cum
-				resultLine.Flat += sl.Flat
+				resultLine.Cum = resultLine.Cum.Add(sl.Cum)
+				resultLine.Flat = resultLine.Flat.Add(sl.Flat)
 			}
 
 			key := fmt.Sprintf("%s:%d", query.Filename, query.LineNumber)
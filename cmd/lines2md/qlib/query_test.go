@@ -6,6 +6,7 @@ import (
 
 	"github.com/Lslightly/pprof2csv/analyzer"
 	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/srcpath"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -45,8 +46,8 @@ func TestMatchQueries(t *testing.T) {
 			key := fmt.Sprintf("%s:%d", query.Filename, query.LineNumber)
 			if expected, exists := expectedResults[key]; exists {
 				resultLine := matchedResults[key]
-				assert.Equal(t, common.ParseDuration(expected.flat), resultLine.Flat, "Flat time mismatch for %s", key)
-				assert.Equal(t, common.ParseDuration(expected.cum), resultLine.Cum, "Cum time mismatch for %s", key)
+				assert.Equal(t, common.ParseDuration(expected.flat), resultLine.Flat.Duration(), "Flat time mismatch for %s", key)
+				assert.Equal(t, common.ParseDuration(expected.cum), resultLine.Cum.Duration(), "Cum time mismatch for %s", key)
 			}
 		}
 	}
@@ -212,8 +213,8 @@ func TestMatchQueriesProtoActorGo(t *testing.T) {
 					if expected, exists := tc.expectedResults[key]; exists {
 						resultLine := matchedResults[key]
 						assert.NotNil(t, resultLine)
-						assert.Equal(t, common.ParseDuration(expected.flat), resultLine.Flat, "Flat time mismatch for %s", key)
-						assert.Equal(t, common.ParseDuration(expected.cum), resultLine.Cum, "Cum time mismatch for %s", key)
+						assert.Equal(t, common.ParseDuration(expected.flat), resultLine.Flat.Duration(), "Flat time mismatch for %s", key)
+						assert.Equal(t, common.ParseDuration(expected.cum), resultLine.Cum.Duration(), "Cum time mismatch for %s", key)
 					}
 				}
 			}
@@ -283,11 +284,61 @@ func TestShowFrom(t *testing.T) {
 					if expected, exists := tc.expectedResults[key]; exists {
 						resultLine := matchedResults[key]
 						assert.NotNil(t, resultLine)
-						assert.Equal(t, common.ParseDuration(expected.flat), resultLine.Flat, "Flat time mismatch for %s", key)
-						assert.Equal(t, common.ParseDuration(expected.cum), resultLine.Cum, "Cum time mismatch for %s", key)
+						assert.Equal(t, common.ParseDuration(expected.flat), resultLine.Flat.Duration(), "Flat time mismatch for %s", key)
+						assert.Equal(t, common.ParseDuration(expected.cum), resultLine.Cum.Duration(), "Cum time mismatch for %s", key)
 					}
 				}
 			}
 		})
 	}
 }
+
+func TestMatchQueriesContention(t *testing.T) {
+	// block.pprof defaults to the delay sample type
+	allLines, err := analyzer.LoadProfileData(common.AbsPathFromRoot("test/contention/block.pprof"), "")
+	if err != nil {
+		t.Fatalf("Failed to load and analyze profile: %v", err)
+	}
+
+	querySections, err := ParseQueryFile(common.AbsPathFromRoot("test/contention/query.txt"))
+	if err != nil {
+		t.Fatalf("Failed to parse query file %v", err)
+	}
+
+	matchedResults := MatchQueries(querySections, allLines)
+
+	expectedResults := map[string]struct {
+		flat string
+		cum  string
+	}{
+		// sync.(*Mutex).Lock is inlined into line 19 and gets the flat delay
+		"test/contention/contention.go:19": {flat: "0ns", cum: "1.297210694s"},
+		"test/contention/contention.go:37": {flat: "0ns", cum: "436.289904ms"},
+	}
+	for key, expected := range expectedResults {
+		resultLine := matchedResults[key]
+		assert.NotNil(t, resultLine)
+		assert.Equal(t, common.ParseDuration(expected.flat), resultLine.Flat.Duration(), "Flat time mismatch for %s", key)
+		assert.Equal(t, common.ParseDuration(expected.cum), resultLine.Cum.Duration(), "Cum time mismatch for %s", key)
+	}
+}
+
+func TestNormalizePaths(t *testing.T) {
+	// Queries written on another machine match the normalized profile paths
+	profPath := common.AbsPathFromRoot("test/protoactor-go/BenchmarkPushPop/cpu-100-default.out")
+	allLines, err := analyzer.LoadProfileData(profPath, "")
+	assert.Nil(t, err)
+
+	querySections := []QuerySection{CreateQuerySection("runtime.mallocgcSmallScanNoHeader", []string{
+		"/usr/local/go/src/runtime/malloc.go:1399,span.freeIndexForScan = span.freeindex",
+		"src/runtime/malloc.go:1399,span.freeIndexForScan = span.freeindex",
+	})}
+	var n srcpath.Normalizer
+	NormalizePaths(querySections, &n)
+	assert.Equal(t, "$GOROOT/src/runtime/malloc.go", querySections[0].Queries[0].Filename)
+	assert.Equal(t, "src/runtime/malloc.go", querySections[0].Queries[1].Filename)
+
+	matchedResults := MatchQueries(querySections, allLines)
+	assert.Equal(t, common.ParseDuration("2.86s"), matchedResults["$GOROOT/src/runtime/malloc.go:1399"].Cum.Duration())
+	assert.Equal(t, common.ParseDuration("2.86s"), matchedResults["src/runtime/malloc.go:1399"].Cum.Duration())
+}
@@ -3,13 +3,19 @@ package lib
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/Lslightly/pprof2csv/analyzer"
 	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/models"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestMallocgcPercent(t *testing.T) {
-	res, err := MallocgcPercent(filepath.Join(common.RootDir(), "test/go_parser/default.out"), "go/parser.BenchmarkParseOnly", "go/parser.BenchmarkParseOnly")
+	res, err := MallocgcPercent([]string{filepath.Join(common.RootDir(), "test/go_parser/default.out")}, "go/parser.BenchmarkParseOnly", analyzer.Options{ShowFrom: "go/parser.BenchmarkParseOnly"})
 	assert.Nil(t, err)
 	assert.Equal(t, 43.28628302569671, res.Percentage)
+	assert.Equal(t, models.UnitNanoseconds, res.Unit)
+	assert.Equal(t, time.Duration(res.MallocgcValue), res.MallocgcTime)
+	assert.Equal(t, time.Duration(res.DenominatorValue), res.Denominator)
 }
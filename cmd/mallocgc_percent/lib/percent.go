@@ -6,18 +6,29 @@ import (
 	"time"
 
 	"github.com/Lslightly/pprof2csv/analyzer"
+	"github.com/Lslightly/pprof2csv/models"
 )
 
+// Result is the mallocgc share of a profile. MallocgcTime and Denominator are only set for
+// time profiles, MallocgcValue and DenominatorValue hold the values in Unit for every
+// sample type, e.g. bytes for -sample_index alloc_space.
 type Result struct {
-	MallocgcTime  time.Duration `json:"mallocgc_time"`
-	Denominator   time.Duration `json:"denominator"`
-	Percentage    float64       `json:"percentage"`
-	ShowFrom      string        `json:"show_from,omitempty"`
-	DenomFuncName string        `json:"denom_func_name,omitempty"`
+	MallocgcTime     time.Duration `json:"mallocgc_time"`
+	Denominator      time.Duration `json:"denominator"`
+	MallocgcValue    int64         `json:"mallocgc_value"`
+	DenominatorValue int64         `json:"denominator_value"`
+	Unit             models.Unit   `json:"unit"`
+	Percentage       float64       `json:"percentage"`
+	ShowFrom         string        `json:"show_from,omitempty"`
+	DenomFuncName    string        `json:"denom_func_name,omitempty"`
 }
 
-func MallocgcPercent(profilePath, showFrom, denomFunc string) (Result, error) {
-	_, funcStats, err := analyzer.LoadProfileDataWithFunctionStats(profilePath, showFrom)
+// MallocgcPercent analyzes the merged profiles of profilePaths (see loader.ReadFiles).
+// opts.ShowFrom and opts.Filter filter the samples of the numerator, and of the denominator
+// if denomFunc is set.
+func MallocgcPercent(profilePaths []string, denomFunc string, opts analyzer.Options) (Result, error) {
+	showFrom := opts.ShowFrom
+	_, funcStats, err := analyzer.LoadProfilesWithOptions(profilePaths, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading profile: %v\n", err)
 		os.Exit(1)
@@ -25,7 +36,7 @@ func MallocgcPercent(profilePath, showFrom, denomFunc string) (Result, error) {
 
 	_, mallocgcTime := analyzer.SumFuncTime(funcStats, func(name string) bool { return name == "runtime.mallocgc" })
 
-	var denominator time.Duration
+	var denominator models.Value
 
 	if denomFunc != "" {
 		if stat, exists := funcStats[denomFunc]; exists {
@@ -38,22 +49,28 @@ func MallocgcPercent(profilePath, showFrom, denomFunc string) (Result, error) {
 			}
 		}
 	} else {
-		denominator, err = analyzer.GetTotalProfileTime(profilePath)
+		denominator, err = analyzer.GetTotalProfileValue(profilePaths, opts.SampleIndex)
 		if err != nil {
 			return Result{}, fmt.Errorf("Error getting total profile time: %v\n", err)
 		}
 	}
 
-	if denominator == 0 {
+	if denominator.Amount == 0 {
 		return Result{}, fmt.Errorf("Error: denominator is zero, cannot calculate percentage")
 	}
 
-	percentage := float64(mallocgcTime) / float64(denominator) * 100
-	return Result{
-		MallocgcTime:  mallocgcTime,
-		Denominator:   denominator,
-		DenomFuncName: denomFunc,
-		ShowFrom:      showFrom,
-		Percentage:    percentage,
-	}, nil
+	percentage := float64(mallocgcTime.Amount) / float64(denominator.Amount) * 100
+	result := Result{
+		MallocgcValue:    mallocgcTime.Amount,
+		DenominatorValue: denominator.Amount,
+		Unit:             denominator.Unit,
+		DenomFuncName:    denomFunc,
+		ShowFrom:         showFrom,
+		Percentage:       percentage,
+	}
+	if result.Unit == models.UnitNanoseconds {
+		result.MallocgcTime = mallocgcTime.Duration()
+		result.Denominator = denominator.Duration()
+	}
+	return result, nil
 }
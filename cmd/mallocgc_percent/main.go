@@ -6,19 +6,33 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Lslightly/pprof2csv/analyzer"
 	"github.com/Lslightly/pprof2csv/cmd/mallocgc_percent/lib"
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/loader"
+	"github.com/Lslightly/pprof2csv/models"
 )
 
 var (
-	inputProfile = flag.String("i", "", "Input pprof profile file")
-	showFrom     = flag.String("show_from", "", "Only include mallocgc samples whose stacktrace contains this function (for numerator)")
-	denomFunc    = flag.String("denom_func", "", "Function name to use as denominator (default: total profile sample time/show_from if the option is provided)")
-	format       = flag.String("format", "text", "Output format: text or json")
+	inputProfiles common.StringsFlag
+	showFrom      = flag.String("show_from", "", "Only include mallocgc samples whose stacktrace contains this function (for numerator), an alias of -focus '^function$'")
+	denomFunc     = flag.String("denom_func", "", "Function name to use as denominator (default: total profile sample time/show_from if the option is provided)")
+	format        = flag.String("format", "text", "Output format: text or json")
+	perFrameCum   = flag.Bool("per_frame_cum", false, "Add a sample to cum once per frame, as before, instead of once per function. Cum of recursive functions can then exceed the total")
+	binary        = flag.String("bin", "", "Binary the profile was collected from, locations without line information are symbolized against it")
+	sampleIndex   = flag.String("sample_index", "", "Sample type to analyze, by name (e.g. cpu, alloc_space) or index. Empty uses the profile default")
+	filter        analyzer.Filter
 )
 
+func init() {
+	analyzer.AddFilterFlags(flag.CommandLine, &filter)
+	flag.Var(&inputProfiles, "i", "Input pprof profile file, - for stdin or an http(s) /debug/pprof URL. Repeat the flag or use a glob pattern to merge several profiles")
+	loader.AddFetchFlags(flag.CommandLine, &loader.DefaultFetchOptions)
+}
+
 func validateFlags() error {
-	if *inputProfile == "" {
-		return fmt.Errorf("input file is required\nUsage: mallocgc_percent -i <profile.pprof> [-show_from <function>] [-denom_func <function>] [-format text|json]")
+	if len(inputProfiles) == 0 {
+		return fmt.Errorf("input file is required\nUsage: mallocgc_percent -i <profile.pprof> [-show_from <function>] [-focus <regexp>] [-denom_func <function>] [-sample_index <type>] [-bin <binary>] [-format text|json]")
 	}
 	if *format != "text" && *format != "json" {
 		return fmt.Errorf("format must be 'text' or 'json'")
@@ -37,7 +51,8 @@ func main() {
 		os.Exit(1)
 	}
 
-	result, err := lib.MallocgcPercent(*inputProfile, *showFrom, *denomFunc)
+	loader.DefaultFetchOptions.DefaultSavePath(loader.SavePathFor(""))
+	result, err := lib.MallocgcPercent(inputProfiles, *denomFunc, analyzer.Options{ShowFrom: *showFrom, SampleIndex: *sampleIndex, Binary: *binary, PerFrameCum: *perFrameCum, Filter: filter})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -61,8 +76,8 @@ func main() {
 		} else {
 			fmt.Println("Denominator: Total Profile Time")
 		}
-		fmt.Printf("MallocGC Time: %s\n", result.MallocgcTime)
-		fmt.Printf("Denominator:   %s\n", result.Denominator)
+		fmt.Printf("MallocGC Time: %s\n", common.FormatValue(models.Value{Amount: result.MallocgcValue, Unit: result.Unit}, ""))
+		fmt.Printf("Denominator:   %s\n", common.FormatValue(models.Value{Amount: result.DenominatorValue, Unit: result.Unit}, ""))
 		fmt.Printf("Percentage:    %f%%\n", result.Percentage)
 	}
 }
@@ -3,12 +3,16 @@ package common
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/Lslightly/pprof2csv/models"
 )
 
 func ParseDuration(s string) time.Duration {
@@ -59,6 +63,129 @@ func FormatDuration(d time.Duration, unit string) string {
 	}
 }
 
+// FormatBytes formats a byte count for tables.
+// If unit is empty, it prints the exact count with a "B" suffix (e.g., "1572864B"), so that
+// CSVs keep every byte.
+// If unit is specified ("B", "KB", "MB", "GB"), it formats in that unit without suffix.
+func FormatBytes(n int64, unit string) string {
+	switch unit {
+	case "":
+		return fmt.Sprintf("%dB", n)
+	case "B":
+		return fmt.Sprintf("%d", n)
+	case "KB":
+		return fmt.Sprintf("%.2f", float64(n)/(1<<10))
+	case "MB":
+		return fmt.Sprintf("%.2f", float64(n)/(1<<20))
+	case "GB":
+		return fmt.Sprintf("%.2f", float64(n)/(1<<30))
+	default:
+		return FormatBytes(n, "")
+	}
+}
+
+// HumanBytes formats a byte count in the largest binary unit that keeps the value >= 1
+// (e.g., "1.50MB"), rounded to two decimals.
+func HumanBytes(n int64) string {
+	suffixes := []string{"B", "KB", "MB", "GB", "TB"}
+	v := float64(n)
+	i := 0
+	for ; i < len(suffixes)-1 && (v >= 1024 || v <= -1024); i++ {
+		v /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.2f%s", v, suffixes[i])
+}
+
+// FormatValue formats a sample value according to its unit.
+// unit is the display unit passed to FormatDuration for time values or FormatBytes for byte values.
+// Counts and custom units (e.g. cycles) are always printed as integers.
+// A Value without unit is formatted as time for compatibility with zero values.
+func FormatValue(v models.Value, unit string) string {
+	switch v.Unit {
+	case models.UnitNanoseconds, "":
+		return FormatDuration(v.Duration(), unit)
+	case models.UnitBytes:
+		return FormatBytes(v.Amount, unit)
+	default:
+		return fmt.Sprintf("%d", v.Amount)
+	}
+}
+
+// FormatHumanValue is FormatValue for reports read by people, e.g. markdown. With empty
+// unit, byte values are printed rounded by HumanBytes instead of exactly.
+func FormatHumanValue(v models.Value, unit string) string {
+	if v.Unit == models.UnitBytes && unit == "" {
+		return HumanBytes(v.Amount)
+	}
+	return FormatValue(v, unit)
+}
+
+// Abs returns the absolute value of n, values of diff profiles may be negative.
+func Abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// ParseBytes parses a byte count formatted by FormatBytes with empty unit (e.g. "512B"),
+// a rounded one formatted by HumanBytes (e.g. "1.50MB") or a plain integer.
+func ParseBytes(s string) int64 {
+	suffixes := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+	}
+	for _, sf := range suffixes {
+		if num, ok := strings.CutSuffix(s, sf.suffix); ok {
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				log.Panicf("error parsing bytes %s: %v", s, err)
+			}
+			return int64(math.Round(f * sf.scale))
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		log.Panicf("error parsing bytes %s: %v", s, err)
+	}
+	return n
+}
+
+// ParseValue parses a value formatted by FormatValue with empty display unit.
+// Time values are parsed with ParseDuration, byte values with ParseBytes, other units as integers.
+func ParseValue(s string, u models.Unit) models.Value {
+	switch u {
+	case models.UnitNanoseconds, "":
+		return models.TimeValue(ParseDuration(s))
+	case models.UnitBytes:
+		return models.Value{Amount: ParseBytes(s), Unit: u}
+	default:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			log.Panicf("error parsing %s value %s: %v", u, s, err)
+		}
+		return models.Value{Amount: n, Unit: u}
+	}
+}
+
+// InferUnit infers the unit of a value formatted by FormatValue with empty display unit.
+// Plain integers are counts, strings ending with "B" are bytes and other strings are durations.
+// Custom units (e.g. cycles) are formatted as plain integers, so they are inferred as counts.
+func InferUnit(s string) models.Unit {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return models.UnitCount
+	}
+	if strings.HasSuffix(s, "B") {
+		return models.UnitBytes
+	}
+	return models.UnitNanoseconds
+}
+
 /*
 callerDir return the dir of caller of callerDir
 
@@ -85,15 +212,40 @@ func AbsPathFromCur(relativePath string) string {
 	return filepath.Join(callerDir(2), relativePath)
 }
 
-func Runcmd(cwd, name string, args ...string) (cmd *exec.Cmd, err error) {
+// Runcmd runs the command name with args in cwd, out is its combined stdout and stderr.
+func Runcmd(cwd, name string, args ...string) (cmd *exec.Cmd, out []byte, err error) {
+	return RuncmdEnv(cwd, nil, name, args...)
+}
+
+// RuncmdEnv is Runcmd with env, e.g. GOGC=off, added to the environment of the command.
+func RuncmdEnv(cwd string, env []string, name string, args ...string) (cmd *exec.Cmd, out []byte, err error) {
 	cmd = exec.Command(name, args...)
 	cmd.Dir = cwd
-	return cmd, cmd.Run()
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err = cmd.CombinedOutput()
+	return cmd, out, err
 }
 
-func RuncmdCheck(cwd, name string, args ...string) {
-	cmd, err := Runcmd(cwd, name, args...)
+// RuncmdCheck is Runcmd that panics with the output of the command if it fails.
+func RuncmdCheck(cwd, name string, args ...string) (out []byte) {
+	cmd, out, err := Runcmd(cwd, name, args...)
 	if err != nil {
-		log.Panicf("cmd %s run error(return code %d): %v", cmd.String(), cmd.ProcessState.ExitCode(), err)
+		log.Panicf("cmd %s run error(return code %d): %v\n%s", cmd.String(), cmd.ProcessState.ExitCode(), err, out)
 	}
+	return out
+}
+
+// StringsFlag is a flag.Value that collects the values of a repeated flag,
+// e.g. -i a.out -i b.out.
+type StringsFlag []string
+
+func (f *StringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *StringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
 }
@@ -3,6 +3,8 @@ package common
 import (
 	"testing"
 	"time"
+
+	"github.com/Lslightly/pprof2csv/models"
 )
 
 func TestFormatDuration(t *testing.T) {
@@ -37,3 +39,115 @@ func TestFormatDuration(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		in   int64
+		want string
+		unit string
+	}{
+		{
+			in:   512,
+			want: "512B",
+			unit: "",
+		},
+		{
+			in:   6553600,
+			want: "6553600B",
+			unit: "",
+		},
+		{
+			in:   64000,
+			want: "62.50",
+			unit: "KB",
+		},
+		{
+			in:   64000,
+			want: "64000",
+			unit: "B",
+		},
+	}
+	for _, tc := range testCases {
+		if got := FormatBytes(tc.in, tc.unit); got != tc.want {
+			t.Errorf("want %s, got %s for %d", tc.want, got, tc.in)
+		}
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	testCases := map[int64]string{
+		512:     "512B",
+		6553600: "6.25MB",
+		6618720: "6.31MB",
+	}
+	for in, want := range testCases {
+		if got := HumanBytes(in); got != want {
+			t.Errorf("want %s, got %s for %d", want, got, in)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	testCases := []struct {
+		in   string
+		unit models.Unit
+		want models.Value
+	}{
+		{
+			in:   "6.05s",
+			unit: models.UnitNanoseconds,
+			want: models.TimeValue(6050 * time.Millisecond),
+		},
+		{
+			in:   "6618720B",
+			unit: models.UnitBytes,
+			want: models.Value{Amount: 6618720, Unit: models.UnitBytes},
+		},
+		{
+			in:   "512B",
+			unit: models.UnitBytes,
+			want: models.Value{Amount: 512, Unit: models.UnitBytes},
+		},
+		{
+			in:   "1234",
+			unit: "cycles",
+			want: models.Value{Amount: 1234, Unit: "cycles"},
+		},
+	}
+	for _, tc := range testCases {
+		got := ParseValue(tc.in, tc.unit)
+		if got != tc.want {
+			t.Errorf("want %v, got %v for %s", tc.want, got, tc.in)
+		}
+		if s := FormatValue(got, ""); s != tc.in {
+			t.Errorf("want %s, got %s when formatting %v", tc.in, s, got)
+		}
+	}
+}
+
+func TestInferUnit(t *testing.T) {
+	testCases := map[string]models.Unit{
+		"0":      models.UnitCount,
+		"1000":   models.UnitCount,
+		"0B":     models.UnitBytes,
+		"1.50MB": models.UnitBytes,
+		"0ns":    models.UnitNanoseconds,
+		"6.05s":  models.UnitNanoseconds,
+	}
+	for in, want := range testCases {
+		if got := InferUnit(in); got != want {
+			t.Errorf("want %s, got %s for %s", want, got, in)
+		}
+	}
+}
+
+func TestRuncmdEnv(t *testing.T) {
+	dir := t.TempDir()
+	_, out, err := RuncmdEnv(dir, []string{"PPROF2CSV_TEST=1"}, "sh", "-c", "echo $PPROF2CSV_TEST; pwd; echo fail >&2; exit 3")
+	if err == nil {
+		t.Errorf("want error for exit code 3")
+	}
+	if want := "1\n" + dir + "\nfail\n"; string(out) != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
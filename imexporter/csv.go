@@ -5,14 +5,28 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"time"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/Lslightly/pprof2csv/common"
 	"github.com/Lslightly/pprof2csv/models"
 )
 
 // CSVExporter converts source line timing data to CSV format
-type CSVExporter struct{}
+type CSVExporter struct {
+	// Totals, if set, adds flat%, cum% and sum% columns relative to Totals.Total to the
+	// line and function CSVs, sum% being the running total of flat% over the rows.
+	// If Totals has a CPUTime and a Duration, flat_cores and cum_cores columns give
+	// the average number of CPUs used.
+	Totals *models.ProfileSummary
+	// MultiTotals, if set, adds flat%_<t>, cum%_<t> and sum%_<t> columns, and the
+	// flat_cores_<t> and cum_cores_<t> columns of CPU profiles, to the CSVs of
+	// ExportMulti, with one summary per sample type t, as Totals.
+	MultiTotals []*models.ProfileSummary
+}
 
 // New creates a new CSVExporter instance
 func New() *CSVExporter {
@@ -20,22 +34,43 @@ func New() *CSVExporter {
 }
 
 // Export writes the source line data to a CSV writer.
-// unit specifies the time unit for output (e.g., "s", "ms", "us", "ns"). Empty string uses default format.
+// unit specifies the unit for output (e.g., "s", "ms", "us", "ns" for time, "B", "KB", "MB", "GB" for bytes).
+// Empty string uses default format.
 func (e *CSVExporter) Export(w io.Writer, lines []*models.SourceLine, unit string) error {
+	return e.exportLines(w, lines, unit, false)
+}
+
+// ExportByLabel is like Export, but writes the Label of the lines, e.g. of
+// analyzer.AnalyzeByLabel, in an additional label column.
+func (e *CSVExporter) ExportByLabel(w io.Writer, lines []*models.SourceLine, unit string) error {
+	return e.exportLines(w, lines, unit, true)
+}
+
+// exportLines writes the source lines, with a label column if withLabel is set.
+func (e *CSVExporter) exportLines(w io.Writer, lines []*models.SourceLine, unit string, withLabel bool) error {
 	csvWriter := csv.NewWriter(w)
 	defer csvWriter.Flush()
 
-	// Write header
-	header := []string{"file", "line", "function", "flat", "cum"}
+	// Write header, unit is the unit of flat and cum (e.g. "nanoseconds", "bytes", "count"),
+	// inlined tells whether the line was executed through inlining into the inlined_into functions
+	header := []string{"file", "line", "function", "flat", "cum", "unit", "inlined", "inlined_into"}
+	if withLabel {
+		header = append(header, "label")
+	}
+	header = append(header, shareHeader(e.Totals, "")...)
 	if err := csvWriter.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
-	// Write data rows
-	for _, line := range lines {
-		// Convert nanoseconds to human-readable time format (e.g., 1.234ms)
-		cumulativeTimeStr := common.FormatDuration(time.Duration(line.Cum), unit)
-		flatTimeStr := common.FormatDuration(time.Duration(line.Flat), unit)
+	// Write data rows, sum% restarts at each label group
+	var sum models.Value
+	for i, line := range lines {
+		if withLabel && i > 0 && line.Label != lines[i-1].Label {
+			sum = models.Value{}
+		}
+		// Convert values to their text form (e.g., 1.234ms, 1572864B)
+		cumulativeTimeStr := common.FormatValue(line.Cum, unit)
+		flatTimeStr := common.FormatValue(line.Flat, unit)
 
 		record := []string{
 			line.Filename,
@@ -43,7 +78,14 @@ func (e *CSVExporter) Export(w io.Writer, lines []*models.SourceLine, unit strin
 			line.FunctionName,
 			flatTimeStr,
 			cumulativeTimeStr,
+			string(line.Cum.Unit),
+			strconv.FormatBool(len(line.InlinedInto) > 0),
+			formatInlinedInto(line.InlinedInto),
+		}
+		if withLabel {
+			record = append(record, line.Label)
 		}
+		record = append(record, shareRecord(e.Totals, line.Flat, line.Cum, &sum)...)
 
 		if err := csvWriter.Write(record); err != nil {
 			return fmt.Errorf("failed to write CSV record for %s:%d: %w", line.Filename, line.LineNumber, err)
@@ -58,25 +100,534 @@ func (e *CSVExporter) Export(w io.Writer, lines []*models.SourceLine, unit strin
 	return nil
 }
 
+// formatInlinedInto joins the functions a line was inlined into with ";".
+func formatInlinedInto(inlinedInto []string) string {
+	return strings.Join(inlinedInto, ";")
+}
+
+// parseInlinedInto splits an inlined_into column written by formatInlinedInto.
+func parseInlinedInto(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ";")
+}
+
 // buildSourceLine build SourceLine from record
-func buildSourceLine(record []string) *models.SourceLine {
+func buildSourceLine(record []string, u models.Unit) *models.SourceLine {
 	return &models.SourceLine{
 		Filename:     record[0],
 		LineNumber:   common.ParseInt(record[1]),
 		FunctionName: record[2],
-		Cum:          common.ParseDuration(record[4]),
-		Flat:         common.ParseDuration(record[3]),
+		Cum:          common.ParseValue(record[4], u),
+		Flat:         common.ParseValue(record[3], u),
 	}
 }
 
+// Import reads source lines written by Export or ExportByLabel with empty unit.
+// CSV files without unit column are read as time values, the inlined and label columns are optional.
 func Import(r io.Reader) (sls []*models.SourceLine) {
 	csvReader := csv.NewReader(r)
 	rs, err := csvReader.ReadAll()
 	if err != nil {
 		log.Panicf("error reading csv: %v", err)
 	}
+	hasUnit := len(rs) > 0 && len(rs[0]) > 5 && rs[0][5] == "unit"
+	hasInlined := hasUnit && len(rs[0]) > 7 && rs[0][7] == "inlined_into"
+	hasLabel := hasInlined && len(rs[0]) > 8 && rs[0][8] == "label"
 	for _, record := range rs[1:] { // ignore header
-		sls = append(sls, buildSourceLine(record))
+		u := models.UnitNanoseconds
+		if hasUnit {
+			u = models.Unit(record[5])
+		}
+		sl := buildSourceLine(record, u)
+		if hasInlined {
+			sl.InlinedInto = parseInlinedInto(record[7])
+		}
+		if hasLabel {
+			sl.Label = record[8]
+		}
+		sls = append(sls, sl)
 	}
 	return
 }
+
+// ExportMulti writes source lines with the values of several sample types to a CSV writer.
+// For each sample type t in sampleTypes a flat_<t> and a cum_<t> column are written.
+// unit is the display unit as in Export.
+func (e *CSVExporter) ExportMulti(w io.Writer, sampleTypes []string, lines []*models.MultiSourceLine, unit string) error {
+	return e.ExportMultiWithDerived(w, sampleTypes, nil, lines, unit)
+}
+
+// ExportMultiWithDerived is like ExportMulti, but also writes a flat_<m> and a cum_<m>
+// column for each derived metric m in derived, see analyzer.DeriveMetrics.
+// Ratios with zero denominator are written as empty strings.
+// The inlined and inlined_into columns of Export follow, then the columns of e.MultiTotals.
+func (e *CSVExporter) ExportMultiWithDerived(w io.Writer, sampleTypes []string, derived []models.DerivedMetric, lines []*models.MultiSourceLine, unit string) error {
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	// Write header
+	header := []string{"file", "line", "function"}
+	for _, st := range sampleTypes {
+		header = append(header, "flat_"+st, "cum_"+st)
+	}
+	for _, m := range derived {
+		header = append(header, "flat_"+m.Name, "cum_"+m.Name)
+	}
+	header = append(header, "inlined", "inlined_into")
+	if e.MultiTotals != nil {
+		for i, st := range sampleTypes {
+			header = append(header, shareHeader(e.MultiTotals[i], "_"+st)...)
+		}
+	}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows
+	sums := make([]models.Value, len(sampleTypes))
+	for _, line := range lines {
+		record := []string{
+			line.Filename,
+			fmt.Sprintf("%d", line.LineNumber),
+			line.FunctionName,
+		}
+		for i := range sampleTypes {
+			record = append(record, common.FormatValue(line.Flat[i], unit), common.FormatValue(line.Cum[i], unit))
+		}
+		for i := range derived {
+			record = append(record, formatRatio(line.FlatDerived[i]), formatRatio(line.CumDerived[i]))
+		}
+		record = append(record, strconv.FormatBool(len(line.InlinedInto) > 0), formatInlinedInto(line.InlinedInto))
+		if e.MultiTotals != nil {
+			for i := range sampleTypes {
+				record = append(record, shareRecord(e.MultiTotals[i], line.Flat[i], line.Cum[i], &sums[i])...)
+			}
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record for %s:%d: %w", line.Filename, line.LineNumber, err)
+		}
+	}
+
+	// Check for any errors during writing
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV data: %w", err)
+	}
+
+	return nil
+}
+
+// ExportFunctions writes the per-function stats to a CSV writer, sorted by cum descending
+// and functions of equal cum by name. unit is the display unit as in Export.
+func (e *CSVExporter) ExportFunctions(w io.Writer, funcStats map[string]*models.FunctionStat, unit string) error {
+	stats := make([]*models.FunctionStat, 0, len(funcStats))
+	for _, stat := range funcStats {
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Cum.Amount > stats[j].Cum.Amount ||
+			(stats[i].Cum.Amount == stats[j].Cum.Amount && stats[i].FunctionName < stats[j].FunctionName)
+	})
+	return e.exportFunctions(w, stats, unit, false)
+}
+
+// ExportFunctionsByLabel writes the function stats, e.g. of analyzer.AnalyzeByLabel,
+// in their order with their Label in an additional label column.
+func (e *CSVExporter) ExportFunctionsByLabel(w io.Writer, stats []*models.FunctionStat, unit string) error {
+	return e.exportFunctions(w, stats, unit, true)
+}
+
+// exportFunctions writes the function stats, with a label column if withLabel is set.
+func (e *CSVExporter) exportFunctions(w io.Writer, stats []*models.FunctionStat, unit string, withLabel bool) error {
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	// Write header
+	header := []string{"function", "flat", "cum", "unit"}
+	if withLabel {
+		header = append(header, "label")
+	}
+	header = append(header, shareHeader(e.Totals, "")...)
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows, sum% restarts at each label group
+	var sum models.Value
+	for i, stat := range stats {
+		if withLabel && i > 0 && stat.Label != stats[i-1].Label {
+			sum = models.Value{}
+		}
+		record := []string{
+			stat.FunctionName,
+			common.FormatValue(stat.Flat, unit),
+			common.FormatValue(stat.Cum, unit),
+			string(stat.Cum.Unit),
+		}
+		if withLabel {
+			record = append(record, stat.Label)
+		}
+		record = append(record, shareRecord(e.Totals, stat.Flat, stat.Cum, &sum)...)
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record for %s: %w", stat.FunctionName, err)
+		}
+	}
+
+	// Check for any errors during writing
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV data: %w", err)
+	}
+
+	return nil
+}
+
+// ExportRootFunctions writes the per-root-function stats to a CSV writer.
+// unit is the display unit as in Export.
+func (e *CSVExporter) ExportRootFunctions(w io.Writer, roots []*models.RootFunctionStat, unit string) error {
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	// Write header
+	header := []string{"function", "file", "start_line", "value", "unit"}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows
+	for _, root := range roots {
+		record := []string{
+			root.FunctionName,
+			root.Filename,
+			fmt.Sprintf("%d", root.StartLine),
+			common.FormatValue(root.Value, unit),
+			string(root.Value.Unit),
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record for %s: %w", root.FunctionName, err)
+		}
+	}
+
+	// Check for any errors during writing
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV data: %w", err)
+	}
+
+	return nil
+}
+
+// hasCores reports whether the cores columns of totals are written, see CSVExporter.Totals.
+func hasCores(totals *models.ProfileSummary) bool {
+	return totals.CPUTime > 0 && totals.Duration > 0
+}
+
+// shareHeader returns the header of the columns written by shareRecord for totals,
+// with suffix appended to the column names, e.g. flat%_delay for suffix _delay.
+func shareHeader(totals *models.ProfileSummary, suffix string) []string {
+	if totals == nil {
+		return nil
+	}
+	header := []string{"flat%" + suffix, "cum%" + suffix, "sum%" + suffix}
+	if hasCores(totals) {
+		header = append(header, "flat_cores"+suffix, "cum_cores"+suffix)
+	}
+	return header
+}
+
+// shareRecord returns the percentages of flat and cum in totals and the CPUs they used.
+// sum is the running total of the flat values of the previous rows, flat is added to it.
+// Percentages of a zero total are left empty.
+func shareRecord(totals *models.ProfileSummary, flat, cum models.Value, sum *models.Value) []string {
+	if totals == nil {
+		return nil
+	}
+	*sum = sum.Add(flat)
+	share := func(v models.Value) float64 {
+		if totals.Total.Amount == 0 {
+			return math.NaN()
+		}
+		return float64(v.Amount) / float64(totals.Total.Amount)
+	}
+	percent := func(v models.Value) string {
+		if s := share(v); !math.IsNaN(s) {
+			return fmt.Sprintf("%.2f", s*100)
+		}
+		return ""
+	}
+	record := []string{percent(flat), percent(cum), percent(*sum)}
+	if hasCores(totals) {
+		cores := func(v models.Value) string {
+			return formatRatio(share(v) * totals.CPUTime.Seconds() / totals.Duration.Seconds())
+		}
+		record = append(record, cores(flat), cores(cum))
+	}
+	return record
+}
+
+// formatRatio formats a derived metric, NaN is written as empty string.
+func formatRatio(r float64) string {
+	if math.IsNaN(r) {
+		return ""
+	}
+	return fmt.Sprintf("%.4f", r)
+}
+
+// ImportMulti reads source lines written by ExportMulti with empty unit.
+// The sample types are taken from the flat_<type> and cum_<type> column names,
+// the unit of each value is inferred with common.InferUnit.
+// Columns of models.DerivedMetrics and the percentage columns of MultiTotals are skipped.
+func ImportMulti(r io.Reader) (sampleTypes []string, lines []*models.MultiSourceLine) {
+	csvReader := csv.NewReader(r)
+	rs, err := csvReader.ReadAll()
+	if err != nil {
+		log.Panicf("error reading csv: %v", err)
+	}
+	if len(rs) == 0 {
+		return
+	}
+
+	header := rs[0]
+	inlinedIdx := slices.Index(header, "inlined")
+	hasInlined := inlinedIdx >= 0 && inlinedIdx+1 < len(header) && header[inlinedIdx+1] == "inlined_into"
+	if hasInlined {
+		header = header[:inlinedIdx]
+	}
+	if len(header) < 3 || (len(header)-3)%2 != 0 {
+		log.Panicf("invalid header of multi sample type csv: %v", header)
+	}
+	end := len(header)
+	for i := 3; i < len(header); i += 2 {
+		st, ok := strings.CutPrefix(header[i], "flat_")
+		if !ok || header[i+1] != "cum_"+st {
+			log.Panicf("invalid columns %s,%s of multi sample type csv, want flat_<type>,cum_<type>", header[i], header[i+1])
+		}
+		if slices.ContainsFunc(models.DerivedMetrics, func(m models.DerivedMetric) bool { return m.Name == st }) {
+			// derived metrics follow the sample types
+			end = i
+			break
+		}
+		sampleTypes = append(sampleTypes, st)
+	}
+
+	for _, record := range rs[1:] {
+		line := &models.MultiSourceLine{
+			Filename:     record[0],
+			LineNumber:   common.ParseInt(record[1]),
+			FunctionName: record[2],
+		}
+		for i := 3; i < end; i += 2 {
+			line.Flat = append(line.Flat, common.ParseValue(record[i], common.InferUnit(record[i])))
+			line.Cum = append(line.Cum, common.ParseValue(record[i+1], common.InferUnit(record[i+1])))
+		}
+		if hasInlined {
+			line.InlinedInto = parseInlinedInto(record[inlinedIdx+1])
+		}
+		lines = append(lines, line)
+	}
+	return
+}
+
+// ExportAllocSizes writes the per-line allocation size histogram to a CSV writer.
+// unit is the display unit of bytes as in Export.
+func (e *CSVExporter) ExportAllocSizes(w io.Writer, buckets []*models.AllocSizeBucket, unit string) error {
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	// Write header
+	header := []string{"file", "line", "function", "size_class", "objects", "bytes"}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows
+	for _, b := range buckets {
+		record := []string{
+			b.Filename,
+			fmt.Sprintf("%d", b.LineNumber),
+			b.FunctionName,
+			fmt.Sprintf("%d", b.SizeClass),
+			common.FormatValue(b.Objects, unit),
+			common.FormatValue(b.Bytes, unit),
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record for %s:%d: %w", b.Filename, b.LineNumber, err)
+		}
+	}
+
+	// Check for any errors during writing
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV data: %w", err)
+	}
+
+	return nil
+}
+
+// ExportIndex writes the summaries of the profiles of a directory tree to a CSV writer.
+// unit is the display unit of the total as in Export.
+func (e *CSVExporter) ExportIndex(w io.Writer, summaries []*models.ProfileSummary, unit string) error {
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	// Write header
+	header := []string{"path", "benchmark", "kind", "setting", "variant", "sample_type", "total", "unit", "samples", "duration"}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows
+	for _, s := range summaries {
+		record := []string{
+			s.Path,
+			s.Benchmark,
+			s.Kind,
+			s.Setting,
+			s.Variant,
+			s.SampleType,
+			common.FormatValue(s.Total, unit),
+			string(s.Total.Unit),
+			fmt.Sprintf("%d", s.Samples),
+			s.Duration.String(),
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record for %s: %w", s.Path, err)
+		}
+	}
+
+	// Check for any errors during writing
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV data: %w", err)
+	}
+
+	return nil
+}
+
+// ExportAddresses writes the per-address stats to a CSV writer. Addresses and offsets
+// are written in hex. The disassembly column is written if disassembly is set.
+// unit is the display unit as in Export.
+func (e *CSVExporter) ExportAddresses(w io.Writer, stats []*models.AddressStat, unit string, disassembly bool) error {
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	// Write header
+	header := []string{"address", "mapping", "offset", "function", "file", "line", "flat", "cum", "unit"}
+	if disassembly {
+		header = append(header, "disassembly")
+	}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows
+	for _, as := range stats {
+		record := []string{
+			fmt.Sprintf("0x%x", as.Address),
+			as.MappingFile,
+			fmt.Sprintf("0x%x", as.Offset),
+			as.FunctionName,
+			as.Filename,
+			fmt.Sprintf("%d", as.LineNumber),
+			common.FormatValue(as.Flat, unit),
+			common.FormatValue(as.Cum, unit),
+			string(as.Cum.Unit),
+		}
+		if disassembly {
+			record = append(record, as.Disassembly)
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record for 0x%x: %w", as.Address, err)
+		}
+	}
+
+	// Check for any errors during writing
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV data: %w", err)
+	}
+
+	return nil
+}
+
+// ExportEdges writes the call graph edges to a CSV writer, one row per caller, call
+// site line and callee. unit is the display unit as in Export.
+func (e *CSVExporter) ExportEdges(w io.Writer, edges []*models.EdgeStat, unit string) error {
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	// Write header, inlined tells whether the callee is inlined at the call site
+	header := []string{"caller", "caller_file", "caller_line", "callee", "weight", "unit", "inlined"}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows
+	for _, edge := range edges {
+		record := []string{
+			edge.CallerFunction,
+			edge.CallerFilename,
+			fmt.Sprintf("%d", edge.CallerLine),
+			edge.CalleeFunction,
+			common.FormatValue(edge.Weight, unit),
+			string(edge.Weight.Unit),
+			strconv.FormatBool(edge.Inlined),
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record for %s -> %s: %w", edge.CallerFunction, edge.CalleeFunction, err)
+		}
+	}
+
+	// Check for any errors during writing
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV data: %w", err)
+	}
+
+	return nil
+}
+
+// ExportKHop writes the k-hop callers or callees of a function, e.g. of
+// analyzer.AnalyzeKHop, to a CSV writer. percent is their cum in percent of the
+// cum of the function. The path column, the functions from caller to callee
+// separated by ";", is written if withPaths is set. unit is the display unit as in Export.
+func (e *CSVExporter) ExportKHop(w io.Writer, stats []*models.KHopStat, unit string, withPaths bool) error {
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	// Write header
+	header := []string{"function", "flat", "cum", "unit", "percent"}
+	if withPaths {
+		header = append(header, "path")
+	}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write data rows
+	for _, stat := range stats {
+		record := []string{
+			stat.FunctionName,
+			common.FormatValue(stat.Flat, unit),
+			common.FormatValue(stat.Cum, unit),
+			string(stat.Cum.Unit),
+			fmt.Sprintf("%.2f", stat.Percent),
+		}
+		if withPaths {
+			record = append(record, strings.Join(stat.Path, ";"))
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record for %s: %w", stat.FunctionName, err)
+		}
+	}
+
+	// Check for any errors during writing
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV data: %w", err)
+	}
+
+	return nil
+}
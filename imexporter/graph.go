@@ -0,0 +1,224 @@
+package imexporter
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/Lslightly/pprof2csv/models"
+)
+
+// ExportDOT writes the call graph in the Graphviz DOT language, e.g. for
+// dot -Tsvg. Like go tool pprof -dot, a node shows the flat and cum of its function,
+// its font grows with flat, and an edge is thicker the more weight it has.
+// Inlined calls are dashed. unit is the display unit as in Export.
+func ExportDOT(w io.Writer, graph *models.CallGraph, unit string) error {
+	var b strings.Builder
+	b.WriteString("digraph \"callgraph\" {\n")
+	b.WriteString("node [style=filled fillcolor=\"#f8f8f8\" shape=box]\n")
+
+	var maxFlat, maxWeight int64
+	for _, node := range graph.Nodes {
+		maxFlat = max(maxFlat, common.Abs(node.Flat.Amount))
+	}
+	for _, e := range graph.Edges {
+		maxWeight = max(maxWeight, common.Abs(e.Weight.Amount))
+	}
+
+	for _, node := range graph.Nodes {
+		label := fmt.Sprintf("%s\\n%s (%s)\\nof %s (%s)", dotEscape(node.FunctionName),
+			common.FormatValue(node.Flat, unit), percentOf(node.Flat, graph.Total),
+			common.FormatValue(node.Cum, unit), percentOf(node.Cum, graph.Total))
+		fontSize := 8 + 24*fraction(node.Flat.Amount, maxFlat)
+		fmt.Fprintf(&b, "N%d [label=\"%s\" tooltip=\"%s\" fontsize=%.0f]\n", node.ID, label, dotEscape(node.Filename), fontSize)
+	}
+	for _, e := range graph.Edges {
+		label := common.FormatValue(e.Weight, unit)
+		style := ""
+		if e.Inlined {
+			label += " (inline)"
+			style = " style=dashed"
+		}
+		penWidth := 1 + 5*fraction(e.Weight.Amount, maxWeight)
+		fmt.Fprintf(&b, "N%d -> N%d [label=\" %s\" penwidth=%.2f%s]\n", e.Caller.ID, e.Callee.ID, dotEscape(label), penWidth, style)
+	}
+	b.WriteString("}\n")
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write DOT graph: %w", err)
+	}
+	return nil
+}
+
+// dotEscape escapes s for a quoted DOT string.
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// percentOf formats v in percent of total, e.g. "12.34%".
+func percentOf(v, total models.Value) string {
+	if total.Amount == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", float64(v.Amount)/float64(total.Amount)*100)
+}
+
+// fraction returns |n| / maxN, or 0 if maxN is 0.
+func fraction(n, maxN int64) float64 {
+	if maxN == 0 {
+		return 0
+	}
+	return math.Min(float64(common.Abs(n))/float64(maxN), 1)
+}
+
+// graphML is the document written by ExportGraphML.
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string         `xml:"id,attr"`
+		EdgeDefault string         `xml:"edgedefault,attr"`
+		Data        []graphMLData  `xml:"data"`
+		Nodes       []graphMLEntry `xml:"node"`
+		Edges       []graphMLEntry `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLEntry struct {
+	ID     string        `xml:"id,attr,omitempty"`
+	Source string        `xml:"source,attr,omitempty"`
+	Target string        `xml:"target,attr,omitempty"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ExportGraphML writes the call graph as GraphML, e.g. for Gephi, yEd or networkx.
+// The graph has the unit and total of the values, nodes have function, file, flat
+// and cum, edges have weight and inlined. Values are written in the profile unit.
+func ExportGraphML(w io.Writer, graph *models.CallGraph) error {
+	var doc graphML
+	doc.XMLNS = "http://graphml.graphdrawing.org/xmlns"
+	doc.Keys = []graphMLKey{
+		{ID: "unit", For: "graph", Name: "unit", Type: "string"},
+		{ID: "total", For: "graph", Name: "total", Type: "long"},
+		{ID: "function", For: "node", Name: "function", Type: "string"},
+		{ID: "file", For: "node", Name: "file", Type: "string"},
+		{ID: "flat", For: "node", Name: "flat", Type: "long"},
+		{ID: "cum", For: "node", Name: "cum", Type: "long"},
+		{ID: "weight", For: "edge", Name: "weight", Type: "long"},
+		{ID: "inlined", For: "edge", Name: "inlined", Type: "boolean"},
+	}
+	doc.Graph.ID = "callgraph"
+	doc.Graph.EdgeDefault = "directed"
+	doc.Graph.Data = []graphMLData{
+		{Key: "unit", Value: string(graph.Total.Unit)},
+		{Key: "total", Value: fmt.Sprint(graph.Total.Amount)},
+	}
+	for _, node := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLEntry{
+			ID: fmt.Sprintf("n%d", node.ID),
+			Data: []graphMLData{
+				{Key: "function", Value: node.FunctionName},
+				{Key: "file", Value: node.Filename},
+				{Key: "flat", Value: fmt.Sprint(node.Flat.Amount)},
+				{Key: "cum", Value: fmt.Sprint(node.Cum.Amount)},
+			},
+		})
+	}
+	for _, e := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEntry{
+			Source: fmt.Sprintf("n%d", e.Caller.ID),
+			Target: fmt.Sprintf("n%d", e.Callee.ID),
+			Data: []graphMLData{
+				{Key: "weight", Value: fmt.Sprint(e.Weight.Amount)},
+				{Key: "inlined", Value: fmt.Sprint(e.Inlined)},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write GraphML graph: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write GraphML graph: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// graphJSON is the document written by ExportGraphJSON.
+type graphJSON struct {
+	Unit  string          `json:"unit"`
+	Total int64           `json:"total"`
+	Nodes []graphJSONNode `json:"nodes"`
+	Edges []graphJSONEdge `json:"edges"`
+}
+
+type graphJSONNode struct {
+	ID       int    `json:"id"`
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Flat     int64  `json:"flat"`
+	Cum      int64  `json:"cum"`
+}
+
+type graphJSONEdge struct {
+	Source  int   `json:"source"`
+	Target  int   `json:"target"`
+	Weight  int64 `json:"weight"`
+	Inlined bool  `json:"inlined"`
+}
+
+// ExportGraphJSON writes the call graph as a JSON node and edge list. Edges refer to
+// the node ids of their caller (source) and callee (target). Values are written in
+// the profile unit.
+func ExportGraphJSON(w io.Writer, graph *models.CallGraph) error {
+	doc := graphJSON{
+		Unit:  string(graph.Total.Unit),
+		Total: graph.Total.Amount,
+		Nodes: make([]graphJSONNode, 0, len(graph.Nodes)),
+		Edges: make([]graphJSONEdge, 0, len(graph.Edges)),
+	}
+	for _, node := range graph.Nodes {
+		doc.Nodes = append(doc.Nodes, graphJSONNode{
+			ID:       node.ID,
+			Function: node.FunctionName,
+			File:     node.Filename,
+			Flat:     node.Flat.Amount,
+			Cum:      node.Cum.Amount,
+		})
+	}
+	for _, e := range graph.Edges {
+		doc.Edges = append(doc.Edges, graphJSONEdge{
+			Source:  e.Caller.ID,
+			Target:  e.Callee.ID,
+			Weight:  e.Weight.Amount,
+			Inlined: e.Inlined,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write JSON graph: %w", err)
+	}
+	return nil
+}
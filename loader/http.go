@@ -0,0 +1,213 @@
+package loader
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FetchOptions controls how profiles are fetched from /debug/pprof HTTP endpoints.
+type FetchOptions struct {
+	// Seconds, if positive, is added as seconds parameter to URLs without one,
+	// e.g. the duration of /debug/pprof/profile or the delta of /debug/pprof/heap.
+	Seconds int
+	// Timeout of the whole request. If zero, it is the profile duration plus one minute.
+	Timeout time.Duration
+	// SavePath, if non-empty, is the file where the raw fetched profile is saved.
+	// If it has no extension, the extension of the fetched encoding is appended,
+	// e.g. cpu.pb.gz for a gzipped proto or heap.txt for ?debug=1. See SavePathFor.
+	SavePath string
+	// Save is set by -save_profile. If SavePath is empty, the command derives it with
+	// DefaultSavePath.
+	Save bool
+}
+
+// DefaultFetchOptions are the options used by ReadFile for URLs.
+var DefaultFetchOptions FetchOptions
+
+// AddFetchFlags defines the -seconds, -timeout and -save_profile flags in fs, which set o.
+// -save_profile takes an optional path, commands derive the path of a bare -save_profile
+// from their output with o.DefaultSavePath.
+func AddFetchFlags(fs *flag.FlagSet, o *FetchOptions) {
+	fs.IntVar(&o.Seconds, "seconds", 0, "Duration in seconds of profiles fetched from http(s) URLs, added as seconds parameter if the URL has none")
+	fs.DurationVar(&o.Timeout, "timeout", 0, "Timeout for fetching profiles from http(s) URLs (default: profile duration + 1m)")
+	fs.Var(saveFlag{o}, "save_profile", "Save the raw profiles fetched from http(s) URLs, -save_profile=path to a given file. The extension of the fetched encoding is added to paths without one, e.g. .pb.gz (default path: next to the output)")
+}
+
+// saveFlag is the -save_profile flag. Like a bool flag it may be given without value,
+// -save_profile=path sets the path.
+type saveFlag struct {
+	o *FetchOptions
+}
+
+func (f saveFlag) String() string {
+	if f.o == nil {
+		return ""
+	}
+	return f.o.SavePath
+}
+
+func (f saveFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.o.Save = true
+	case "false":
+		f.o.Save, f.o.SavePath = false, ""
+	default:
+		f.o.Save, f.o.SavePath = true, value
+	}
+	return nil
+}
+
+func (f saveFlag) IsBoolFlag() bool {
+	return true
+}
+
+// DefaultSavePath sets o.SavePath to path if -save_profile was given without path.
+func (o *FetchOptions) DefaultSavePath(path string) {
+	if o.Save && o.SavePath == "" {
+		o.SavePath = path
+	}
+}
+
+// SavePathFor returns the path where the profile converted to output is saved,
+// output without extension, e.g. out/cpu for out/cpu.csv, or profile if output is
+// empty, i.e. stdout.
+func SavePathFor(output string) string {
+	if output == "" {
+		return "profile"
+	}
+	return strings.TrimSuffix(output, filepath.Ext(output))
+}
+
+// IsURL reports whether path is an http(s) URL.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+var (
+	fetchedMu sync.Mutex
+	fetched   = make(map[string][]byte)
+)
+
+// fetchOnce fetches rawURL with DefaultFetchOptions, the data of a URL is fetched only once.
+func fetchOnce(rawURL string) ([]byte, error) {
+	fetchedMu.Lock()
+	defer fetchedMu.Unlock()
+	if data, ok := fetched[rawURL]; ok {
+		return data, nil
+	}
+	data, err := Fetch(rawURL, DefaultFetchOptions)
+	if err != nil {
+		return nil, err
+	}
+	fetched[rawURL] = data
+	return data, nil
+}
+
+// Fetch fetches the profile at the http(s) URL rawURL, e.g.
+// http://localhost:6060/debug/pprof/profile?seconds=30.
+func Fetch(rawURL string, o FetchOptions) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile URL %s: %v", rawURL, err)
+	}
+	query := u.Query()
+	if o.Seconds > 0 && query.Get("seconds") == "" {
+		query.Set("seconds", strconv.Itoa(o.Seconds))
+		u.RawQuery = query.Encode()
+	}
+
+	timeout := o.Timeout
+	if timeout == 0 {
+		seconds, _ := strconv.Atoi(query.Get("seconds"))
+		timeout = time.Duration(seconds)*time.Second + time.Minute
+	}
+	client := &http.Client{Timeout: timeout}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching profile: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching profile %s: %v", u, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching profile %s: %s: %s", u, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if o.SavePath != "" {
+		if err := saveProfile(o.SavePath, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+var (
+	savedMu sync.Mutex
+	saved   = make(map[string]bool)
+)
+
+// saveProfile saves the fetched data to path, overwriting the file of a previous run.
+// If path has no extension, the extension of the encoding of data is appended. Several
+// profiles saved to the same path in one run, e.g. because URLs are merged, are numbered,
+// e.g. cpu.pb.gz, cpu.1.pb.gz.
+func saveProfile(path string, data []byte) error {
+	base, ext := splitExt(path)
+	if ext == "" {
+		ext = encodingExt(data)
+	}
+	savedMu.Lock()
+	name := base + ext
+	for i := 1; saved[name]; i++ {
+		name = fmt.Sprintf("%s.%d%s", base, i, ext)
+	}
+	saved[name] = true
+	savedMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("error creating directory for fetched profile: %v", err)
+	}
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		return fmt.Errorf("error saving fetched profile: %v", err)
+	}
+	return nil
+}
+
+// splitExt splits path into the path without extension and the extension,
+// which is .pb.gz for gzipped protos.
+func splitExt(path string) (base, ext string) {
+	if base, ok := strings.CutSuffix(path, ".pb.gz"); ok {
+		return base, ".pb.gz"
+	}
+	ext = filepath.Ext(path)
+	return strings.TrimSuffix(path, ext), ext
+}
+
+// encodingExt returns the file extension of profile data: .pb.gz or .pb for protos,
+// .txt for text, e.g. the output of ?debug=1, and .prof for other data.
+func encodingExt(data []byte) string {
+	switch DetectEncoding(data) {
+	case EncodingGzipProto:
+		return ".pb.gz"
+	case EncodingProto:
+		return ".pb"
+	}
+	if utf8.Valid(data) {
+		return ".txt"
+	}
+	return ".prof"
+}
@@ -0,0 +1,183 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/pprof/profile"
+)
+
+// Stdin is the input path that reads the profile from standard input.
+const Stdin = "-"
+
+// Encoding is the encoding of profile data.
+type Encoding string
+
+const (
+	EncodingGzipProto Encoding = "gzipped proto"
+	EncodingProto     Encoding = "uncompressed proto"
+	EncodingLegacy    Encoding = "legacy" // text heap/contention/thread or binary CPU profiles
+	EncodingUnknown   Encoding = "unknown"
+)
+
+var (
+	stdinOnce sync.Once
+	stdinData []byte
+	stdinErr  error
+)
+
+// ReadFile reads the profile data from path, or from standard input if path is Stdin,
+// or fetches it with DefaultFetchOptions if path is an http(s) URL (see Fetch).
+// Standard input is read only once, later calls return the same data, so that
+// several analyses of the same input work. The same holds for URLs.
+func ReadFile(path string) ([]byte, error) {
+	if IsURL(path) {
+		return fetchOnce(path)
+	}
+	if path != Stdin {
+		return os.ReadFile(path)
+	}
+	stdinOnce.Do(func() {
+		stdinData, stdinErr = io.ReadAll(os.Stdin)
+	})
+	return stdinData, stdinErr
+}
+
+// DetectEncoding returns the encoding of profile data, i.e. which of the formats
+// understood by profile.Parse it is in. It returns EncodingUnknown if the data
+// cannot be parsed.
+func DetectEncoding(data []byte) Encoding {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		return EncodingGzipProto
+	}
+	if _, err := profile.ParseUncompressed(data); err == nil {
+		return EncodingProto
+	}
+	if _, err := profile.Parse(bytes.NewReader(data)); err == nil {
+		return EncodingLegacy
+	}
+	return EncodingUnknown
+}
+
+// ExpandPaths expands the glob patterns in paths, e.g. "BenchmarkPushPop/cpu-*.out".
+// Stdin, URLs and paths without glob metacharacters are kept as they are.
+// It returns an error if a pattern matches no file.
+func ExpandPaths(paths []string) ([]string, error) {
+	var expanded []string
+	for _, path := range paths {
+		if path == Stdin || IsURL(path) || !strings.ContainsAny(path, "*?[") {
+			expanded = append(expanded, path)
+			continue
+		}
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %v", path, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no profile matches %s", path)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// ProfileExts are the file extensions of the profiles found by FindProfiles.
+var ProfileExts = []string{".pb.gz", ".pprof", ".prof", ".pb", ".out"}
+
+// FindProfiles walks the directory tree root and returns the files with one of
+// ProfileExts in lexical order. The files are not checked to be profiles, e.g. a
+// coverage profile cover.out is returned as well.
+func FindProfiles(root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && TrimProfileExt(path) != path {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// TrimProfileExt returns path without its extension if it is one of ProfileExts.
+func TrimProfileExt(path string) string {
+	for _, ext := range ProfileExts {
+		if strings.HasSuffix(path, ext) {
+			return strings.TrimSuffix(path, ext)
+		}
+	}
+	return path
+}
+
+// ReadFiles reads the profiles at paths after expanding glob patterns (see ExpandPaths).
+// A single profile is returned as it is. Several profiles are merged with
+// profile.Merge and returned as gzipped proto data. Profiles with different
+// sample types cannot be merged, the error names the first incompatible file.
+func ReadFiles(paths []string) ([]byte, error) {
+	paths, err := ExpandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no input profile")
+	}
+	if len(paths) == 1 {
+		return ReadFile(paths[0])
+	}
+
+	profiles := make([]*profile.Profile, 0, len(paths))
+	for _, path := range paths {
+		data, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		p, err := profile.ParseData(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse profile data of %s: %w", path, err)
+		}
+		if len(profiles) > 0 && !sameSampleTypes(profiles[0], p) {
+			return nil, fmt.Errorf("cannot merge %s with %s: incompatible sample types %v and %v",
+				path, paths[0], sampleTypes(p), sampleTypes(profiles[0]))
+		}
+		profiles = append(profiles, p)
+	}
+
+	merged, err := profile.Merge(profiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge profiles: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := merged.Write(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write merged profile: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sameSampleTypes reports whether p and q have the same period type and sample types.
+func sameSampleTypes(p, q *profile.Profile) bool {
+	if (p.PeriodType == nil) != (q.PeriodType == nil) ||
+		p.PeriodType != nil && (p.PeriodType.Type != q.PeriodType.Type || p.PeriodType.Unit != q.PeriodType.Unit) {
+		return false
+	}
+	return slices.Equal(sampleTypes(p), sampleTypes(q))
+}
+
+// sampleTypes returns the sample types of p as type/unit strings.
+func sampleTypes(p *profile.Profile) []string {
+	types := make([]string, len(p.SampleType))
+	for i, st := range p.SampleType {
+		types[i] = st.Type + "/" + st.Unit
+	}
+	return types
+}
@@ -0,0 +1,162 @@
+package loader
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Lslightly/pprof2csv/common"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+const legacyHeap = `heap profile: 1: 64 [1: 64] @ heap/1048576
+1: 64 [1: 64] @ 0x401000 0x402000
+`
+
+func TestDetectEncoding(t *testing.T) {
+	gzipped, err := os.ReadFile(common.AbsPathFromRoot("test/loop/cpu.pprof"))
+	assert.Nil(t, err)
+	assert.Equal(t, EncodingGzipProto, DetectEncoding(gzipped))
+
+	p, err := profile.ParseData(gzipped)
+	assert.Nil(t, err)
+	var uncompressed bytes.Buffer
+	assert.Nil(t, p.WriteUncompressed(&uncompressed))
+	assert.Equal(t, EncodingProto, DetectEncoding(uncompressed.Bytes()))
+
+	assert.Equal(t, EncodingLegacy, DetectEncoding([]byte(legacyHeap)))
+	assert.Equal(t, EncodingUnknown, DetectEncoding([]byte("not a profile")))
+}
+
+func TestReadFileStdin(t *testing.T) {
+	stdinPath := filepath.Join(t.TempDir(), "stdin")
+	assert.Nil(t, os.WriteFile(stdinPath, []byte(legacyHeap), 0644))
+	f, err := os.Open(stdinPath)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	oldStdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = oldStdin }()
+
+	// stdin is read once, the second call returns the same data
+	for i := 0; i < 2; i++ {
+		data, err := ReadFile(Stdin)
+		assert.Nil(t, err)
+		assert.Equal(t, legacyHeap, string(data))
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	paths, err := ExpandPaths([]string{Stdin, common.AbsPathFromRoot("test/protoactor-go/BenchmarkPIDSet_Add*/cpu-100-default.out")})
+	assert.Nil(t, err)
+	assert.Len(t, paths, 3)
+	assert.Equal(t, Stdin, paths[0])
+
+	_, err = ExpandPaths([]string{common.AbsPathFromRoot("test/*.nonexistent")})
+	assert.NotNil(t, err)
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/pprof/heap", pprof.Handler("heap"))
+	mux.HandleFunc("/debug/pprof/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// A saved profile of a previous run is overwritten
+	dir := t.TempDir()
+	savePath := filepath.Join(dir, "heap.pb.gz")
+	assert.Nil(t, os.WriteFile(savePath, []byte("previous run"), 0644))
+	data, err := Fetch(server.URL+"/debug/pprof/heap", FetchOptions{SavePath: SavePathFor(filepath.Join(dir, "heap.csv"))})
+	assert.Nil(t, err)
+	assert.Equal(t, EncodingGzipProto, DetectEncoding(data))
+	p, err := profile.ParseData(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "alloc_objects", p.SampleType[0].Type)
+
+	saved, err := os.ReadFile(savePath)
+	assert.Nil(t, err)
+	assert.Equal(t, data, saved)
+
+	// A second profile saved in the same run is numbered, e.g. of merged URLs
+	_, err = Fetch(server.URL+"/debug/pprof/heap", FetchOptions{SavePath: filepath.Join(dir, "heap")})
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(dir, "heap.1.pb.gz"))
+	assert.Nil(t, err)
+
+	// The extension follows the fetched encoding
+	_, err = Fetch(server.URL+"/debug/pprof/heap?debug=1", FetchOptions{SavePath: filepath.Join(dir, "heap-text")})
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(dir, "heap-text.txt"))
+	assert.Nil(t, err)
+
+	_, err = Fetch(server.URL+"/debug/pprof/slow", FetchOptions{Timeout: 100 * time.Millisecond})
+	assert.NotNil(t, err)
+
+	_, err = Fetch(server.URL+"/debug/pprof/missing", FetchOptions{})
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestSaveProfileFlag(t *testing.T) {
+	parse := func(args ...string) FetchOptions {
+		var o FetchOptions
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		AddFetchFlags(fs, &o)
+		assert.Nil(t, fs.Parse(args))
+		o.DefaultSavePath("out/cpu")
+		return o
+	}
+	assert.Equal(t, "", parse().SavePath)
+	assert.Equal(t, "out/cpu", parse("-save_profile").SavePath)
+	assert.Equal(t, "heap.pb.gz", parse("-save_profile=heap.pb.gz").SavePath)
+	assert.Equal(t, "", parse("-save_profile=false").SavePath)
+
+	assert.Equal(t, "out/cpu", SavePathFor("out/cpu.csv"))
+	assert.Equal(t, "profile", SavePathFor(""))
+}
+
+func TestFetchSeconds(t *testing.T) {
+	cpu, err := os.ReadFile(common.AbsPathFromRoot("test/loop/cpu.pprof"))
+	assert.Nil(t, err)
+	var seconds string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seconds = r.URL.Query().Get("seconds")
+		w.Write(cpu)
+	}))
+	defer server.Close()
+
+	data, err := Fetch(server.URL+"/debug/pprof/profile", FetchOptions{Seconds: 5})
+	assert.Nil(t, err)
+	assert.Equal(t, "5", seconds)
+	assert.Equal(t, cpu, data)
+
+	// The seconds parameter of the URL takes precedence
+	_, err = Fetch(server.URL+"/debug/pprof/profile?seconds=2", FetchOptions{Seconds: 5})
+	assert.Nil(t, err)
+	assert.Equal(t, "2", seconds)
+}
+
+func TestReadFileURL(t *testing.T) {
+	server := httptest.NewServer(pprof.Handler("goroutine"))
+	defer server.Close()
+
+	url := server.URL + "/debug/pprof/goroutine?debug=0"
+	paths, err := ExpandPaths([]string{url})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{url}, paths)
+
+	data, err := ReadFiles(paths)
+	assert.Nil(t, err)
+	_, err = profile.ParseData(data)
+	assert.Nil(t, err)
+}
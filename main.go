@@ -3,25 +3,178 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/Lslightly/pprof2csv/analyzer"
+	"github.com/Lslightly/pprof2csv/common"
 	"github.com/Lslightly/pprof2csv/imexporter"
+	"github.com/Lslightly/pprof2csv/loader"
+	"github.com/Lslightly/pprof2csv/srcpath"
 )
 
 // Version of the tool (set at build time)
 var version = "dev"
 
-func main() {
-	// Define command-line flags
-	var (
-		versionFlag = flag.Bool("version", false, "Show version information")
-		outputFile  = flag.String("o", "", "Output CSV file (default: stdout)")
-		inputFile   = flag.String("i", "", "Input pprof profile file")
-		showFrom    = flag.String("show_from", "", "Only include samples whose stacktrace contains this function")
-		unit        = flag.String("unit", "", "Time unit for output (s, ms, us, ns). Empty string uses default format")
-	)
+// Define command-line flags
+var (
+	versionFlag    = flag.Bool("version", false, "Show version information")
+	outputFile     = flag.String("o", "", "Output CSV file (default: stdout). In directory mode, the index file (default: <dir>/index.csv)")
+	showFrom       = flag.String("show_from", "", "Only include samples whose stacktrace contains this function, an alias of -focus '^function$'")
+	unit           = flag.String("unit", "", "Unit for output (s, ms, us, ns for time; B, KB, MB, GB for bytes). Empty string uses default format")
+	sampleIndex    = flag.String("sample_index", "", "Sample type to analyze, by name (e.g. cpu, alloc_space, delay) or index. Empty uses the profile default")
+	allSampleTypes = flag.Bool("all_sample_types", false, "Export flat_<type> and cum_<type> columns for every sample type of the profile (lines granularity only)")
+	perFrameCum    = flag.Bool("per_frame_cum", false, "Add a sample to cum once per frame, as before, instead of once per line and function. Cum of recursive functions can then exceed the total")
+	binary         = flag.String("bin", "", "Binary the profile was collected from, locations without line information are symbolized against it")
+	granularity    = flag.String("granularity", "lines", "Aggregate by source lines, functions, roots (the function started by a go statement, for goroutine profiles), alloc_sizes (object size classes per line, for heap profiles), addresses (instruction addresses), edges (caller -> callee call graph edges), graph (call graph in -graph_format), callers or callees (the -k-hop callers or callees of -target)")
+	graphFormat    = flag.String("graph_format", "dot", "Format of the graph granularity: dot (Graphviz), graphml or json (node and edge list)")
+	nodeFraction   = flag.Float64("nodefraction", analyzer.DefaultNodeFraction, "Graph granularity: hide nodes whose cum is below this fraction of the total")
+	edgeFraction   = flag.Float64("edgefraction", analyzer.DefaultEdgeFraction, "Graph granularity: hide edges whose weight is below this fraction of the total")
+	relativePct    = flag.Bool("relative_percentages", false, "Compute flat%, cum% and sum% relative to the samples selected by -show_from and the filters instead of all samples")
+	groupByLabel   = flag.String("group_by_label", "", "Analyze the samples of each value of this pprof label key separately and add a label column (lines and functions granularity only)")
+	target         = flag.String("target", "", "Function whose callers or callees are aggregated (callers and callees granularity)")
+	khop           = flag.Int("k", 1, "Callers and callees granularity: distance in calls between -target and the aggregated callers or callees")
+	khopPaths      = flag.Bool("paths", false, "Callers and callees granularity: split the callers or callees by the path from caller to callee and add a path column")
+	disasm         = flag.Bool("disasm", false, "Add a disassembly column produced by go tool objdump on the -bin binary (addresses granularity only)")
+	inputFiles     common.StringsFlag
+	paths          srcpath.Normalizer
+	filter         analyzer.Filter
+)
+
+func init() {
+	flag.Var(&inputFiles, "i", "Input pprof profile file, - for stdin or an http(s) /debug/pprof URL. Repeat the flag or use a glob pattern to merge several profiles. A directory converts every profile in it to a sibling CSV and writes an index")
+	loader.AddFetchFlags(flag.CommandLine, &loader.DefaultFetchOptions)
+	srcpath.AddFlags(flag.CommandLine, &paths)
+	analyzer.AddFilterFlags(flag.CommandLine, &filter)
+}
+
+// analyzerOptions returns the analyzer options given by the flags.
+func analyzerOptions() analyzer.Options {
+	return analyzer.Options{ShowFrom: *showFrom, SampleIndex: *sampleIndex, Binary: *binary, Paths: paths, PerFrameCum: *perFrameCum, Filter: filter}
+}
+
+// outputExt returns the file extension of the output of analyze, e.g. .csv or .dot.
+func outputExt() string {
+	if *granularity == "graph" {
+		return "." + *graphFormat
+	}
+	return ".csv"
+}
 
+// outputFormat returns the name of the format of the output of analyze, e.g. CSV or DOT.
+func outputFormat() string {
+	if *granularity == "graph" {
+		return map[string]string{"dot": "DOT", "graphml": "GraphML", "json": "JSON"}[*graphFormat]
+	}
+	return "CSV"
+}
+
+// analyze analyzes the profile data with opts and the granularity given by the flags.
+// The returned export is called once the output is created.
+func analyze(data []byte, opts analyzer.Options) (export func(w io.Writer) error, err error) {
+	csvExporter := imexporter.New()
+	// Line and function CSVs get percentage and CPU utilization columns
+	percentTotals := func() (err error) {
+		csvExporter.Totals, err = analyzer.PercentTotals(data, opts, *relativePct)
+		return err
+	}
+
+	switch *granularity {
+	case "lines":
+		if *groupByLabel != "" {
+			if err := percentTotals(); err != nil {
+				return nil, err
+			}
+			sourceLines, _, err := analyzer.AnalyzeByLabel(data, opts, *groupByLabel)
+			return func(w io.Writer) error { return csvExporter.ExportByLabel(w, sourceLines, *unit) }, err
+		}
+		// All sample types are exported as columns if requested. Block and mutex profiles
+		// are exported with both contentions and delay columns, unless a single sample
+		// type is requested
+		multi := *allSampleTypes
+		var multiIndexes []string
+		if !multi && *sampleIndex == "" {
+			multi, err = analyzer.IsContentionProfile(data)
+			if err != nil {
+				return nil, err
+			}
+			multiIndexes = analyzer.ContentionSampleTypes
+		}
+		if multi {
+			sampleTypes, multiLines, err := analyzer.AnalyzeSampleTypes(data, opts, multiIndexes)
+			if err != nil {
+				return nil, err
+			}
+			csvExporter.MultiTotals, err = analyzer.SampleTypeTotals(data, opts, *relativePct, sampleTypes)
+			if err != nil {
+				return nil, err
+			}
+			// Derived metrics such as IPC are added if their sample types are present
+			derived := analyzer.DeriveMetrics(sampleTypes, multiLines)
+			return func(w io.Writer) error {
+				return csvExporter.ExportMultiWithDerived(w, sampleTypes, derived, multiLines, *unit)
+			}, nil
+		}
+		if err := percentTotals(); err != nil {
+			return nil, err
+		}
+		sourceLines, _, err := analyzer.AnalyzeWithOptions(data, opts)
+		return func(w io.Writer) error { return csvExporter.Export(w, sourceLines, *unit) }, err
+	case "functions":
+		if err := percentTotals(); err != nil {
+			return nil, err
+		}
+		if *groupByLabel != "" {
+			_, funcStats, err := analyzer.AnalyzeByLabel(data, opts, *groupByLabel)
+			return func(w io.Writer) error { return csvExporter.ExportFunctionsByLabel(w, funcStats, *unit) }, err
+		}
+		_, funcStats, err := analyzer.AnalyzeWithOptions(data, opts)
+		return func(w io.Writer) error { return csvExporter.ExportFunctions(w, funcStats, *unit) }, err
+	case "roots":
+		roots, err := analyzer.AnalyzeRootFunctions(data, opts)
+		return func(w io.Writer) error { return csvExporter.ExportRootFunctions(w, roots, *unit) }, err
+	case "alloc_sizes":
+		buckets, err := analyzer.AnalyzeAllocSizes(data, opts)
+		return func(w io.Writer) error { return csvExporter.ExportAllocSizes(w, buckets, *unit) }, err
+	case "addresses":
+		stats, err := analyzer.AnalyzeAddresses(data, opts)
+		if err == nil && *disasm {
+			err = analyzer.AddDisassembly(stats, *binary)
+		}
+		return func(w io.Writer) error { return csvExporter.ExportAddresses(w, stats, *unit, *disasm) }, err
+	case "edges":
+		edges, err := analyzer.AnalyzeEdges(data, opts)
+		return func(w io.Writer) error { return csvExporter.ExportEdges(w, edges, *unit) }, err
+	case "callers", "callees":
+		k := *khop
+		if *granularity == "callees" {
+			k = -k
+		}
+		stats, err := analyzer.AnalyzeKHop(data, opts, *target, k, *khopPaths)
+		return func(w io.Writer) error { return csvExporter.ExportKHop(w, stats, *unit, *khopPaths) }, err
+	case "graph":
+		graph, err := analyzer.BuildCallGraph(data, opts)
+		if err != nil {
+			return nil, err
+		}
+		analyzer.PruneCallGraph(graph, *nodeFraction, *edgeFraction)
+		switch *graphFormat {
+		case "dot":
+			return func(w io.Writer) error { return imexporter.ExportDOT(w, graph, *unit) }, nil
+		case "graphml":
+			return func(w io.Writer) error { return imexporter.ExportGraphML(w, graph) }, nil
+		case "json":
+			return func(w io.Writer) error { return imexporter.ExportGraphJSON(w, graph) }, nil
+		default:
+			return nil, fmt.Errorf("unknown graph format %q, must be one of: dot, graphml, json", *graphFormat)
+		}
+	default:
+		return nil, fmt.Errorf("unknown granularity %q, must be one of: lines, functions, roots, alloc_sizes, addresses, edges, graph, callers, callees", *granularity)
+	}
+}
+
+func main() {
 	// Parse flags
 	flag.Parse()
 
@@ -32,31 +185,80 @@ func main() {
 	}
 
 	// Validate input file
-	if *inputFile == "" {
+	if len(inputFiles) == 0 {
 		fmt.Fprintln(os.Stderr, "Error: input file is required")
 		fmt.Fprintln(os.Stderr, "Usage: pprof2csv -i <profile.pprof>")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
-	// Create components
-	csvExporter := imexporter.New()
+	if *disasm && (*binary == "" || *granularity != "addresses") {
+		fmt.Fprintln(os.Stderr, "Error: -disasm requires -bin and -granularity addresses")
+		os.Exit(1)
+	}
+	if (*granularity == "callers" || *granularity == "callees") && (*target == "" || *khop < 1) {
+		fmt.Fprintln(os.Stderr, "Error: -granularity callers and callees require -target and -k of at least 1")
+		os.Exit(1)
+	}
+	if *allSampleTypes && *granularity != "lines" {
+		fmt.Fprintln(os.Stderr, "Error: -all_sample_types requires -granularity lines")
+		os.Exit(1)
+	}
+	if *groupByLabel != "" && (*allSampleTypes || *granularity != "lines" && *granularity != "functions") {
+		fmt.Fprintln(os.Stderr, "Error: -group_by_label requires -granularity lines or functions and cannot be combined with -all_sample_types")
+		os.Exit(1)
+	}
+
+	// Convert a directory tree
+	if len(inputFiles) == 1 {
+		if info, err := os.Stat(inputFiles[0]); err == nil && info.IsDir() {
+			if err := convertDir(inputFiles[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error converting directory: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 
-	// Load profile data
-	data, err := os.ReadFile(*inputFile)
+	loader.DefaultFetchOptions.DefaultSavePath(loader.SavePathFor(*outputFile))
+
+	// Load profile data, several profiles are merged
+	paths, err := loader.ExpandPaths(inputFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading profile: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := loader.ReadFiles(paths)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading profile: %v\n", err)
 		os.Exit(1)
 	}
+	if len(paths) == 1 {
+		fmt.Fprintf(os.Stderr, "Detected %s profile encoding\n", loader.DetectEncoding(data))
+	} else {
+		fmt.Fprintf(os.Stderr, "Merged %d profiles\n", len(paths))
+	}
+	// The profile is symbolized once, not by every analysis of it
+	opts := analyzerOptions()
+	if *binary != "" {
+		var unsymbolized, total int
+		data, unsymbolized, total, err = analyzer.Symbolize(data, *binary)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error symbolizing profile: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Binary = ""
+		fmt.Fprintf(os.Stderr, "%d of %d samples remain unsymbolized (%s in the CSV)\n", unsymbolized, total, analyzer.UnsymbolizedFunction)
+	}
 
 	// Analyze profile data
-	sourceLines, err := analyzer.Analyze(data, *showFrom)
+	export, err := analyze(data, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error analyzing profile: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Export to CSV
+	// Export to CSV, or to the graph format
 	var output *os.File
 	if *outputFile == "" {
 		// Output to stdout
@@ -72,11 +274,11 @@ func main() {
 		defer output.Close()
 	}
 
-	err = csvExporter.Export(output, sourceLines, *unit)
+	err = export(output)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error exporting CSV: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error exporting %s: %v\n", outputFormat(), err)
 		os.Exit(1)
 	}
 
-	fmt.Fprintf(os.Stderr, "Successfully converted %s to CSV format\n", *inputFile)
+	fmt.Fprintf(os.Stderr, "Successfully converted %s to %s format\n", strings.Join(paths, ", "), outputFormat())
 }
@@ -2,18 +2,155 @@ package models
 
 import "time"
 
-// SourceLine represents timing information for a specific source line
+// SourceLine represents timing information for a specific source line.
+// For non-CPU profiles Cum and Flat hold bytes, counts or other units as indicated by their Unit.
 type SourceLine struct {
 	Filename     string
 	LineNumber   int
 	FunctionName string
-	Cum          time.Duration // Cumulative time
-	Flat         time.Duration // Flat time (time spent directly in this function)
+	Cum          Value // Cumulative time
+	Flat         Value // Flat time (time spent directly in this function)
+	// InlinedInto lists the functions this line was inlined into, sorted. It is empty
+	// if the line was never executed through inlining.
+	InlinedInto []string
+	// Label is the value of the label the samples were grouped by, if any.
+	Label string
 }
 
-// FunctionStat represents aggregated timing information for a specific function
+// FunctionStat represents aggregated timing information for a specific function.
+// For non-CPU profiles Cum and Flat hold bytes, counts or other units as indicated by their Unit.
 type FunctionStat struct {
 	FunctionName string
-	Cum          time.Duration // Cumulative time (self + callees)
-	Flat         time.Duration // Flat time (time spent directly in this function)
+	Cum          Value  // Cumulative time (self + callees)
+	Flat         Value  // Flat time (time spent directly in this function)
+	Label        string // Value of the label the samples were grouped by, if any
+}
+
+// MultiSourceLine represents the values of several sample types for a specific source line,
+// e.g. contentions and delay of a block profile.
+// Flat[i] and Cum[i] belong to the i-th analyzed sample type.
+// FlatDerived[i] and CumDerived[i] belong to the i-th applied DerivedMetric, they are NaN
+// if the denominator is zero.
+type MultiSourceLine struct {
+	Filename     string
+	LineNumber   int
+	FunctionName string
+	Cum          []Value
+	Flat         []Value
+	CumDerived   []float64
+	FlatDerived  []float64
+	InlinedInto  []string // see SourceLine.InlinedInto
+}
+
+// RootFunctionStat represents the aggregated value of samples whose stack starts at a root function.
+// In goroutine profiles the root function is the function started by a go statement,
+// and Value is the number of goroutines running it.
+type RootFunctionStat struct {
+	FunctionName string
+	Filename     string
+	StartLine    int // Line of the function declaration
+	Value        Value
+}
+
+// DerivedMetric is a per-line ratio of two sample types, e.g. instructions per cycle
+// of profiles converted with perf_to_profile.
+type DerivedMetric struct {
+	Name        string
+	Numerator   string // sample type name
+	Denominator string // sample type name
+}
+
+// DerivedMetrics are the derived metrics computed when their sample types are present.
+var DerivedMetrics = []DerivedMetric{
+	{Name: "ipc", Numerator: "instructions", Denominator: "cycles"},
+	{Name: "cpi", Numerator: "cycles", Denominator: "instructions"},
+	{Name: "cache_miss_ratio", Numerator: "cache-misses", Denominator: "cache-references"},
+	{Name: "branch_miss_ratio", Numerator: "branch-misses", Denominator: "branches"},
+	{Name: "l1d_miss_ratio", Numerator: "L1-dcache-load-misses", Denominator: "L1-dcache-loads"},
+	{Name: "llc_miss_ratio", Numerator: "LLC-load-misses", Denominator: "LLC-loads"},
+}
+
+// AllocSizeBucket represents the allocations of a specific source line whose
+// object size falls into a size class.
+type AllocSizeBucket struct {
+	Filename     string
+	LineNumber   int
+	FunctionName string
+	SizeClass    int64 // Upper bound of the object size in bytes
+	Objects      Value // Number of allocated objects
+	Bytes        Value // Allocated bytes
+}
+
+// ProfileSummary summarizes a profile of a directory tree. Benchmark, Kind, Setting and
+// Variant are derived from its path <Benchmark>/<kind>-<setting>-<variant>.out and are
+// empty if the path does not follow this layout.
+type ProfileSummary struct {
+	Path       string
+	Benchmark  string
+	Kind       string
+	Setting    string
+	Variant    string
+	SampleType string        // Sample type of Total
+	Total      Value         // Sum of all samples
+	Samples    int64         // Number of samples, the sum of the samples sample type if present
+	Duration   time.Duration // Profile duration
+	CPUTime    time.Duration // CPU time of the samples of CPU profiles (period type cpu), zero for other profiles
+}
+
+// EdgeStat represents the aggregated value of the samples in which a caller function
+// calls a callee function at a specific source line.
+type EdgeStat struct {
+	CallerFunction string
+	CallerFilename string
+	CallerLine     int // Line of the call in the caller
+	CalleeFunction string
+	Inlined        bool  // The callee is inlined into the caller at this call site
+	Weight         Value // Value of the samples containing the call
+}
+
+// CallGraph is the call graph of a profile with functions as nodes.
+type CallGraph struct {
+	Nodes []*GraphNode // Sorted by cum descending
+	Edges []*GraphEdge // Sorted by weight descending
+	Total Value        // Value of all analyzed samples, the denominator of node and edge fractions
+}
+
+// GraphNode is a function of a CallGraph.
+type GraphNode struct {
+	ID           int // Position of the node in CallGraph.Nodes, starting at 1
+	FunctionName string
+	Filename     string
+	Flat         Value
+	Cum          Value
+}
+
+// GraphEdge is a call of a CallGraph.
+type GraphEdge struct {
+	Caller  *GraphNode
+	Callee  *GraphNode
+	Weight  Value // Value of the samples containing the call
+	Inlined bool  // All calls from Caller to Callee are inlined
+}
+
+// KHopStat represents the samples in which a function calls a target function, or is
+// called by it, k calls away, e.g. the callers of runtime.mallocgc two calls up.
+type KHopStat struct {
+	FunctionName string   // The k-hop caller or callee
+	Path         []string // Functions from the caller to the callee in call order, target included, if requested
+	Flat         Value    // Value of the samples in which the callee end of the path is the leaf
+	Cum          Value    // Value of the samples containing the path
+	Percent      float64  // Cum in percent of the cum of the target
+}
+
+// AddressStat represents the aggregated value of a specific instruction address.
+type AddressStat struct {
+	Address      uint64 // Runtime address of the location
+	MappingFile  string // Binary or shared library of the address
+	Offset       uint64 // Offset of the address in MappingFile
+	FunctionName string // Innermost function and line of the address
+	Filename     string
+	LineNumber   int
+	Cum          Value
+	Flat         Value
+	Disassembly  string // Instruction at the address, empty if not disassembled
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Unit is the unit of a sample value as reported by pprof, e.g. "nanoseconds", "bytes", "count" or "cycles".
+type Unit string
+
+const (
+	UnitNanoseconds Unit = "nanoseconds" // time values are normalized to nanoseconds
+	UnitBytes       Unit = "bytes"
+	UnitCount       Unit = "count"
+)
+
+// Value is a sample value together with its unit.
+type Value struct {
+	Amount int64 // in Unit
+	Unit   Unit
+}
+
+// TimeValue returns the Value of duration d.
+func TimeValue(d time.Duration) Value {
+	return Value{Amount: int64(d), Unit: UnitNanoseconds}
+}
+
+// Duration returns v as time.Duration. It is only meaningful for time values.
+func (v Value) Duration() time.Duration {
+	return time.Duration(v.Amount)
+}
+
+// Add returns the sum of v and o. The unit of the sum is the unit of v,
+// or the unit of o if v has no unit yet (e.g. a zero Value used as accumulator).
+func (v Value) Add(o Value) Value {
+	if v.Unit == "" {
+		v.Unit = o.Unit
+	}
+	v.Amount += o.Amount
+	return v
+}
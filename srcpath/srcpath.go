@@ -0,0 +1,213 @@
+// Package srcpath normalizes the source file paths of profiles, so that profiles
+// built on different machines have the same paths.
+package srcpath
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// GOROOT is the prefix of normalized paths of the Go distribution, e.g. $GOROOT/src/runtime/malloc.go.
+const GOROOT = "$GOROOT/"
+
+// Remap replaces the path prefix Old by New.
+type Remap struct {
+	Old string
+	New string
+}
+
+// ParseRemap parses a remap written as old=new.
+func ParseRemap(s string) (Remap, error) {
+	old, new, ok := strings.Cut(s, "=")
+	if !ok || old == "" {
+		return Remap{}, fmt.Errorf("invalid remap %q, must be old=new", s)
+	}
+	return Remap{Old: old, New: new}, nil
+}
+
+// Normalizer normalizes source file paths. The zero value only applies the automatic
+// normalization of Normalize.
+type Normalizer struct {
+	Remaps       []Remap  // applied first, the first matching remap wins
+	TrimPrefixes []string // removed if no remap matches, the first matching prefix wins
+	Raw          bool     // disables the automatic normalization
+}
+
+// Normalize returns the normalized path of filename, a source file of function.
+// The remaps and trimmed prefixes given by the user are applied first. Otherwise,
+// unless n.Raw is set, files of the Go distribution are rewritten to $GOROOT/src/...,
+// e.g. /home/ci/go/src/runtime/malloc.go of runtime.mallocgc, and files in the module
+// cache to module@version/path, e.g. /home/ci/go/pkg/mod/github.com/!burnt!sushi/toml@v1.3.2/decode.go
+// to github.com/BurntSushi/toml@v1.3.2/decode.go. Other paths are kept as they are.
+func (n *Normalizer) Normalize(filename, function string) string {
+	return n.normalize(filename, function, nil)
+}
+
+// File is a source file of a function.
+type File struct {
+	Filename string
+	Function string
+}
+
+// NormalizeAll returns the normalized paths of files, e.g. of all functions of a profile.
+// In addition to Normalize, a Go distribution recognized by some files is applied to all
+// files in it, e.g. to runtime/atomic_pointer.go of sync/atomic functions.
+func (n *Normalizer) NormalizeAll(files []File) []string {
+	var goroots []string
+	for _, f := range files {
+		if _, ok := n.userRules(f.Filename); ok || n.Raw {
+			continue
+		}
+		if root, ok := goroot(f.Filename, f.Function); ok && !slices.Contains(goroots, root) {
+			goroots = append(goroots, root)
+		}
+	}
+	normalized := make([]string, len(files))
+	for i, f := range files {
+		normalized[i] = n.normalize(f.Filename, f.Function, goroots)
+	}
+	return normalized
+}
+
+// normalize is Normalize, files in goroots are rewritten as files of the Go distribution.
+func (n *Normalizer) normalize(filename, function string, goroots []string) string {
+	if normalized, ok := n.userRules(filename); ok {
+		return normalized
+	}
+	if n.Raw {
+		return filename
+	}
+	if i := strings.LastIndex(filename, "/pkg/mod/"); i >= 0 {
+		return unescapeModulePath(filename[i+len("/pkg/mod/"):])
+	}
+	if root, ok := goroot(filename, function); ok {
+		return GOROOT + filename[len(root):]
+	}
+	for _, root := range goroots {
+		if strings.HasPrefix(filename, root+"src/") {
+			return GOROOT + filename[len(root):]
+		}
+	}
+	return filename
+}
+
+// userRules applies the remaps and trimmed prefixes, it reports whether one matched.
+func (n *Normalizer) userRules(filename string) (string, bool) {
+	for _, r := range n.Remaps {
+		if strings.HasPrefix(filename, r.Old) {
+			return r.New + filename[len(r.Old):], true
+		}
+	}
+	for _, prefix := range n.TrimPrefixes {
+		if strings.HasPrefix(filename, prefix) {
+			return strings.TrimPrefix(filename[len(prefix):], "/"), true
+		}
+	}
+	return filename, false
+}
+
+// goroot returns the directory of the Go distribution, e.g. /usr/local/go/, if filename
+// is a file of the Go distribution, i.e. it is in the src/ directory of the standard
+// library package of function.
+func goroot(filename, function string) (string, bool) {
+	pkg := packagePath(function)
+	if !isStdPackage(pkg) {
+		return "", false
+	}
+	dir := filename[:strings.LastIndex(filename, "/")+1]
+	suffix := "/src/" + pkg + "/"
+	if !strings.HasSuffix(dir, suffix) {
+		return "", false
+	}
+	return dir[:len(dir)-len(suffix)+1], true
+}
+
+// packagePath returns the import path of the package of a Go function name,
+// e.g. internal/runtime/atomic of internal/runtime/atomic.(*Uint32).Load.
+func packagePath(function string) string {
+	// Type parameters of generic functions may contain other package paths
+	if i := strings.Index(function, "["); i >= 0 {
+		function = function[:i]
+	}
+	slash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return function[:slash+1+dot]
+}
+
+// isStdPackage reports whether the import path pkg belongs to the Go distribution,
+// i.e. its first element has no dot.
+func isStdPackage(pkg string) bool {
+	if pkg == "" || pkg == "main" {
+		return false
+	}
+	first, _, _ := strings.Cut(pkg, "/")
+	return !strings.Contains(first, ".")
+}
+
+// unescapeModulePath reverses the case-encoding of module cache paths, !x is X.
+func unescapeModulePath(path string) string {
+	if !strings.Contains(path, "!") {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '!' && i+1 < len(path) {
+			i++
+			b.WriteRune(unicode.ToUpper(rune(path[i])))
+			continue
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// remapsFlag is the flag.Value of -remap.
+type remapsFlag struct{ n *Normalizer }
+
+func (f remapsFlag) String() string {
+	if f.n == nil {
+		return ""
+	}
+	var remaps []string
+	for _, r := range f.n.Remaps {
+		remaps = append(remaps, r.Old+"="+r.New)
+	}
+	return strings.Join(remaps, ",")
+}
+
+func (f remapsFlag) Set(s string) error {
+	r, err := ParseRemap(s)
+	if err != nil {
+		return err
+	}
+	f.n.Remaps = append(f.n.Remaps, r)
+	return nil
+}
+
+// trimFlag is the flag.Value of -trim_path.
+type trimFlag struct{ n *Normalizer }
+
+func (f trimFlag) String() string {
+	if f.n == nil {
+		return ""
+	}
+	return strings.Join(f.n.TrimPrefixes, ",")
+}
+
+func (f trimFlag) Set(s string) error {
+	f.n.TrimPrefixes = append(f.n.TrimPrefixes, s)
+	return nil
+}
+
+// AddFlags defines the -remap, -trim_path and -raw_paths flags in fs, which set n.
+func AddFlags(fs *flag.FlagSet, n *Normalizer) {
+	fs.Var(remapsFlag{n}, "remap", "Replace the source path prefix old by new, written as old=new. Repeatable")
+	fs.Var(trimFlag{n}, "trim_path", "Remove this prefix from source paths. Repeatable")
+	fs.BoolVar(&n.Raw, "raw_paths", false, "Keep the source paths of the Go distribution and module cache as they are instead of $GOROOT/... and module@version/...")
+}
@@ -0,0 +1,65 @@
+package srcpath
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	var n Normalizer
+	for _, tc := range []struct {
+		filename, function, want string
+	}{
+		{"/home/lqw/mygit/go1.24.2/src/runtime/malloc.go", "runtime.mallocgc", "$GOROOT/src/runtime/malloc.go"},
+		{"/usr/local/go/src/internal/runtime/atomic/types.go", "internal/runtime/atomic.(*Uint32).Load", "$GOROOT/src/internal/runtime/atomic/types.go"},
+		{"/usr/local/go/src/sync/atomic/type.go", "sync/atomic.(*Pointer[go.shape.struct { github.com/a/b.x int }]).Load", "$GOROOT/src/sync/atomic/type.go"},
+		{"/usr/local/go/src/runtime/asm_amd64.s", "runtime.goexit", "$GOROOT/src/runtime/asm_amd64.s"},
+		{"/home/ci/go/pkg/mod/github.com/asynkron/protoactor-go@v0.0.0-20240101/actor/pid.go", "github.com/asynkron/protoactor-go/actor.(*PID).Tell", "github.com/asynkron/protoactor-go@v0.0.0-20240101/actor/pid.go"},
+		{"/root/go/pkg/mod/github.com/!burnt!sushi/toml@v1.3.2/decode.go", "github.com/BurntSushi/toml.Decode", "github.com/BurntSushi/toml@v1.3.2/decode.go"},
+		// The file is not in the directory of the package of the function
+		{"/home/ci/src/myproj/runtime/malloc.go", "runtime.mallocgc", "/home/ci/src/myproj/runtime/malloc.go"},
+		{"/home/ci/src/main/main.go", "main.main", "/home/ci/src/main/main.go"},
+		{"/home/lqw/mygit/benchs/protoactor-go/internal/queue/mpsc/mpsc.go", "github.com/asynkron/protoactor-go/internal/queue/mpsc.(*Queue).Push", "/home/lqw/mygit/benchs/protoactor-go/internal/queue/mpsc/mpsc.go"},
+		{"synthetic.go", "main", "synthetic.go"},
+	} {
+		assert.Equal(t, tc.want, n.Normalize(tc.filename, tc.function), tc.filename)
+	}
+}
+
+func TestNormalizeUserRules(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var n Normalizer
+	AddFlags(fs, &n)
+	assert.Nil(t, fs.Parse([]string{
+		"-remap", "/home/lqw/mygit/benchs/protoactor-go=github.com/asynkron/protoactor-go",
+		"-trim_path", "/home/ci/src",
+		"-remap", "/home/lqw/mygit/go1.24.2/=/usr/local/go/",
+	}))
+	assert.Equal(t, "github.com/asynkron/protoactor-go/internal/queue/mpsc/mpsc.go", n.Normalize("/home/lqw/mygit/benchs/protoactor-go/internal/queue/mpsc/mpsc.go", ""))
+	assert.Equal(t, "myproj/main.go", n.Normalize("/home/ci/src/myproj/main.go", "main.main"))
+	// User rules take precedence over the automatic normalization
+	assert.Equal(t, "/usr/local/go/src/runtime/malloc.go", n.Normalize("/home/lqw/mygit/go1.24.2/src/runtime/malloc.go", "runtime.mallocgc"))
+
+	n = Normalizer{Raw: true}
+	assert.Equal(t, "/usr/local/go/src/runtime/malloc.go", n.Normalize("/usr/local/go/src/runtime/malloc.go", "runtime.mallocgc"))
+
+	_, err := ParseRemap("no_equal_sign")
+	assert.NotNil(t, err)
+}
+
+func TestNormalizeAll(t *testing.T) {
+	var n Normalizer
+	normalized := n.NormalizeAll([]File{
+		{"/home/lqw/mygit/go1.24.2/src/runtime/atomic_pointer.go", "sync/atomic.runtime_LoadPointer"},
+		{"/home/lqw/mygit/go1.24.2/src/runtime/malloc.go", "runtime.mallocgc"},
+		{"/home/lqw/mygit/benchs/protoactor-go/actor/pid.go", "github.com/asynkron/protoactor-go/actor.(*PID).Tell"},
+	})
+	assert.Equal(t, []string{
+		"$GOROOT/src/runtime/atomic_pointer.go",
+		"$GOROOT/src/runtime/malloc.go",
+		"/home/lqw/mygit/benchs/protoactor-go/actor/pid.go",
+	}, normalized)
+	assert.Equal(t, "/home/lqw/mygit/go1.24.2/src/runtime/atomic_pointer.go", n.Normalize("/home/lqw/mygit/go1.24.2/src/runtime/atomic_pointer.go", "sync/atomic.runtime_LoadPointer"))
+}
@@ -0,0 +1,81 @@
+package symbolizer
+
+import (
+	"debug/elf"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Instruction is an instruction of a binary disassembled by go tool objdump.
+type Instruction struct {
+	Addr uint64
+	Size uint64
+	Text string // e.g. CALL runtime.mallocgc(SB)
+}
+
+// Disassemble disassembles the functions of the binary with go tool objdump.
+// The instructions are sorted by address.
+func (b *Binary) Disassemble(functions []string) ([]Instruction, error) {
+	if len(functions) == 0 {
+		return nil, nil
+	}
+	quoted := make([]string, len(functions))
+	for i, fn := range functions {
+		quoted[i] = regexp.QuoteMeta(fn)
+	}
+	cmd := exec.Command("go", "tool", "objdump", "-s", "^("+strings.Join(quoted, "|")+")$", b.path)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("cmd %s run error: %v\n%s", cmd.String(), err, exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("cmd %s run error: %v", cmd.String(), err)
+	}
+	return parseObjdump(string(out)), nil
+}
+
+// parseObjdump parses the output of go tool objdump, whose instruction lines are
+// file:line, address, encoding and instruction separated by tabs.
+func parseObjdump(out string) []Instruction {
+	var insts []Instruction
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == '\t' })
+		if len(fields) < 4 || !strings.HasPrefix(fields[1], "0x") {
+			continue
+		}
+		addr, err := strconv.ParseUint(fields[1][2:], 16, 64)
+		if err != nil {
+			continue
+		}
+		insts = append(insts, Instruction{
+			Addr: addr,
+			Size: uint64(len(fields[2]) / 2),
+			Text: strings.TrimSpace(fields[3]),
+		})
+	}
+	sort.Slice(insts, func(i, j int) bool { return insts[i].Addr < insts[j].Addr })
+	return insts
+}
+
+// FindInstruction returns the instruction of insts, sorted by address, that contains addr.
+func FindInstruction(insts []Instruction, addr uint64) (Instruction, bool) {
+	i := sort.Search(len(insts), func(i int) bool { return insts[i].Addr > addr }) - 1
+	if i < 0 || addr >= insts[i].Addr+max(insts[i].Size, 1) {
+		return Instruction{}, false
+	}
+	return insts[i], true
+}
+
+// OffsetAddr translates the file offset off of the binary to its address in the binary.
+func (b *Binary) OffsetAddr(off uint64) uint64 {
+	for _, prog := range b.file.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Off <= off && off < prog.Off+prog.Filesz {
+			return off - prog.Off + prog.Vaddr
+		}
+	}
+	return off
+}
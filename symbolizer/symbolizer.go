@@ -0,0 +1,231 @@
+// Package symbolizer resolves the addresses of unsymbolized profile locations
+// against a local ELF binary, without go tool pprof or addr2line.
+package symbolizer
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/gosym"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/pprof/profile"
+)
+
+// Frame is the function and source line of an address.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Binary resolves addresses of an ELF binary with its DWARF line tables, its Go
+// line table (.gopclntab, kept in binaries built with -ldflags=-s -w) and its ELF
+// symbol table, in this order of preference. Inlined calls are not expanded, an
+// address resolves to its innermost source line.
+type Binary struct {
+	path    string
+	file    *elf.File
+	goTable *gosym.Table
+	lines   []lineEntry  // DWARF line table rows sorted by address
+	syms    []elf.Symbol // function symbols sorted by address
+}
+
+// lineEntry is a row of a DWARF line table. The row covers the addresses up to the next row,
+// unless it ends a sequence.
+type lineEntry struct {
+	addr uint64
+	file string
+	line int
+	end  bool
+}
+
+// Open opens the ELF binary at path and reads its line and symbol tables.
+func Open(path string) (*Binary, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening binary: %v", err)
+	}
+	b := &Binary{path: path, file: f}
+
+	if sect := f.Section(".gopclntab"); sect != nil {
+		data, err := sect.Data()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error reading .gopclntab of %s: %v", path, err)
+		}
+		var textStart uint64
+		if text := f.Section(".text"); text != nil {
+			textStart = text.Addr
+		}
+		if table, err := gosym.NewTable(nil, gosym.NewLineTable(data, textStart)); err == nil {
+			b.goTable = table
+		}
+	}
+
+	if d, err := f.DWARF(); err == nil {
+		b.lines = readLines(d)
+	}
+
+	if syms, err := f.Symbols(); err == nil {
+		for _, sym := range syms {
+			if elf.ST_TYPE(sym.Info) == elf.STT_FUNC && sym.Value != 0 {
+				b.syms = append(b.syms, sym)
+			}
+		}
+		sort.Slice(b.syms, func(i, j int) bool { return b.syms[i].Value < b.syms[j].Value })
+	}
+
+	if b.goTable == nil && len(b.lines) == 0 && len(b.syms) == 0 {
+		f.Close()
+		return nil, fmt.Errorf("binary %s has neither line tables nor symbols", path)
+	}
+	return b, nil
+}
+
+// readLines reads the line tables of all compilation units of d.
+func readLines(d *dwarf.Data) []lineEntry {
+	var lines []lineEntry
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			break
+		}
+		if e.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+		lr, err := d.LineReader(e)
+		if err == nil && lr != nil {
+			var le dwarf.LineEntry
+			for lr.Next(&le) == nil {
+				entry := lineEntry{addr: le.Address, line: le.Line, end: le.EndSequence}
+				if le.File != nil {
+					entry.file = le.File.Name
+				}
+				lines = append(lines, entry)
+			}
+		}
+		r.SkipChildren()
+	}
+	// A sequence may start where another ends, the start must come last to be found
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].addr != lines[j].addr {
+			return lines[i].addr < lines[j].addr
+		}
+		return lines[i].end && !lines[j].end
+	})
+	return lines
+}
+
+// Close closes the binary.
+func (b *Binary) Close() error {
+	return b.file.Close()
+}
+
+// Lookup returns the frame of the address addr of the binary.
+func (b *Binary) Lookup(addr uint64) (Frame, bool) {
+	var frame Frame
+	if b.goTable != nil {
+		if file, line, fn := b.goTable.PCToLine(addr); fn != nil {
+			frame = Frame{Function: fn.Name, File: file, Line: line}
+		}
+	}
+	if frame.Function == "" {
+		i := sort.Search(len(b.syms), func(i int) bool { return b.syms[i].Value > addr }) - 1
+		if i >= 0 && (b.syms[i].Size == 0 || addr < b.syms[i].Value+b.syms[i].Size) {
+			frame.Function = b.syms[i].Name
+		}
+	}
+	if i := sort.Search(len(b.lines), func(i int) bool { return b.lines[i].addr > addr }) - 1; i >= 0 && !b.lines[i].end && b.lines[i].file != "" {
+		frame.File, frame.Line = b.lines[i].file, b.lines[i].line
+	}
+	return frame, frame.Function != ""
+}
+
+// Symbolize adds the function and source line to the locations of p that have no
+// lines and belong to the binary, i.e. whose mapping is missing, is the main mapping
+// or has the same file name as the binary. It returns the number of locations symbolized.
+func (b *Binary) Symbolize(p *profile.Profile) int {
+	funcs := make(map[[2]string]*profile.Function)
+	var maxID uint64
+	for _, fn := range p.Function {
+		funcs[[2]string{fn.Name, fn.Filename}] = fn
+		maxID = max(maxID, fn.ID)
+	}
+
+	symbolized := 0
+	for _, loc := range p.Location {
+		if len(loc.Line) != 0 || !b.owns(p, loc.Mapping) {
+			continue
+		}
+		addr := b.objAddr(loc)
+		// Profile addresses are return addresses, except for the leaf of
+		// legacy profiles, and addr-1 still lies in the same call instruction
+		if addr > 0 {
+			addr--
+		}
+		frame, ok := b.Lookup(addr)
+		if !ok {
+			continue
+		}
+		key := [2]string{frame.Function, frame.File}
+		fn, ok := funcs[key]
+		if !ok {
+			maxID++
+			fn = &profile.Function{ID: maxID, Name: frame.Function, SystemName: frame.Function, Filename: frame.File}
+			p.Function = append(p.Function, fn)
+			funcs[key] = fn
+		}
+		loc.Line = []profile.Line{{Function: fn, Line: int64(frame.Line)}}
+		if m := loc.Mapping; m != nil {
+			m.HasFunctions = true
+			m.HasFilenames = m.HasFilenames || frame.File != ""
+			m.HasLineNumbers = m.HasLineNumbers || frame.Line != 0
+		}
+		symbolized++
+	}
+	return symbolized
+}
+
+// owns reports whether the mapping m of a location of p belongs to the binary.
+func (b *Binary) owns(p *profile.Profile, m *profile.Mapping) bool {
+	if m == nil || (len(p.Mapping) > 0 && m == p.Mapping[0]) {
+		return true
+	}
+	return b.OwnsFile(m.File)
+}
+
+// OwnsFile reports whether the mapping file of a location, as recorded in the profile,
+// is the binary, i.e. it is unknown or has the same file name.
+func (b *Binary) OwnsFile(mappingFile string) bool {
+	return mappingFile == "" || filepath.Base(mappingFile) == filepath.Base(b.path)
+}
+
+// objAddr translates the runtime address of loc to the address in the binary.
+// Addresses of position independent binaries are translated through the file
+// offset of their mapping.
+func (b *Binary) objAddr(loc *profile.Location) uint64 {
+	if b.file.Type != elf.ET_DYN || loc.Mapping == nil {
+		return loc.Address
+	}
+	off := loc.Address - loc.Mapping.Start + loc.Mapping.Offset
+	for _, prog := range b.file.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Off <= off && off < prog.Off+prog.Filesz {
+			return off - prog.Off + prog.Vaddr
+		}
+	}
+	return loc.Address
+}
+
+// SymbolizeFile symbolizes p against the binary at path, see Binary.Symbolize.
+func SymbolizeFile(p *profile.Profile, path string) (int, error) {
+	b, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer b.Close()
+	return b.Symbolize(p), nil
+}
@@ -0,0 +1,137 @@
+package symbolizer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+const allocSource = `package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+var sink [][]byte
+
+//go:noinline
+func alloc() {
+	for i := 0; i < 100; i++ {
+		sink = append(sink, make([]byte, 4096))
+	}
+}
+
+func main() {
+	runtime.MemProfileRate = 1
+	alloc()
+	runtime.GC()
+	f, _ := os.Create(os.Args[1])
+	defer f.Close()
+	pprof.Lookup("allocs").WriteTo(f, 0)
+}
+`
+
+// buildAndProfile builds the alloc program with ldflags and returns the binary path, its
+// heap profile and a copy of the profile with all line information removed.
+func buildAndProfile(t *testing.T, ldflags string) (string, *profile.Profile, *profile.Profile) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module alloc\n\ngo 1.21\n"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(allocSource), 0644))
+	bin := filepath.Join(dir, "alloc")
+	out, err := exec.Command("go", "build", "-C", dir, "-ldflags", ldflags, "-o", bin, ".").CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	profPath := filepath.Join(dir, "heap.pprof")
+	out, err = exec.Command(bin, profPath).CombinedOutput()
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+
+	f, err := os.Open(profPath)
+	assert.Nil(t, err)
+	defer f.Close()
+	orig, err := profile.Parse(f)
+	assert.Nil(t, err)
+	p := orig.Copy()
+	for _, loc := range p.Location {
+		loc.Line = nil
+	}
+	p.Function = nil
+	for _, m := range p.Mapping {
+		m.HasFunctions, m.HasFilenames, m.HasLineNumbers, m.HasInlineFrames = false, false, false, false
+	}
+	return bin, orig, p
+}
+
+// allocLine returns the bytes allocated at main.go:14 in main.alloc, by make and append.
+func allocLine(p *profile.Profile) int64 {
+	var bytes int64
+	for _, s := range p.Sample {
+		if len(s.Location) == 0 || len(s.Location[0].Line) == 0 {
+			continue
+		}
+		line := s.Location[0].Line[0]
+		if line.Function.Name == "main.alloc" && strings.HasSuffix(line.Function.Filename, "main.go") && line.Line == 14 {
+			bytes += s.Value[1]
+		}
+	}
+	return bytes
+}
+
+func TestSymbolize(t *testing.T) {
+	for _, ldflags := range []string{"", "-w", "-s -w"} {
+		bin, orig, p := buildAndProfile(t, ldflags)
+		assert.GreaterOrEqual(t, allocLine(orig), int64(100*4096))
+		assert.Zero(t, allocLine(p))
+
+		n, err := SymbolizeFile(p, bin)
+		assert.Nil(t, err, ldflags)
+		assert.Equal(t, len(p.Location), n, ldflags)
+		assert.Equal(t, allocLine(orig), allocLine(p), ldflags)
+		assert.Nil(t, p.CheckValid())
+	}
+}
+
+func TestOpenNotBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not_binary")
+	assert.Nil(t, os.WriteFile(path, []byte("not a binary"), 0644))
+	_, err := Open(path)
+	assert.NotNil(t, err)
+}
+
+func TestDisassemble(t *testing.T) {
+	bin, orig, _ := buildAndProfile(t, "")
+	b, err := Open(bin)
+	assert.Nil(t, err)
+	defer b.Close()
+
+	insts, err := b.Disassemble([]string{"main.alloc"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, insts)
+
+	// The return addresses of main.alloc follow the calls to the allocator
+	calls := 0
+	for _, loc := range orig.Location {
+		if len(loc.Line) == 0 || loc.Line[len(loc.Line)-1].Function.Name != "main.alloc" {
+			continue
+		}
+		addr := b.OffsetAddr(loc.Address - loc.Mapping.Start + loc.Mapping.Offset)
+		assert.Equal(t, loc.Address, addr)
+		inst, ok := FindInstruction(insts, addr-1)
+		assert.True(t, ok, "0x%x", addr)
+		assert.True(t, strings.HasPrefix(inst.Text, "CALL "), inst.Text)
+		calls++
+	}
+	assert.NotZero(t, calls)
+
+	_, ok := FindInstruction(insts, insts[0].Addr-1)
+	assert.False(t, ok)
+}
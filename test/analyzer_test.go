@@ -1,6 +1,11 @@
 package test
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -11,10 +16,12 @@ import (
 	"github.com/Lslightly/pprof2csv/common"
 	"github.com/Lslightly/pprof2csv/imexporter"
 	"github.com/Lslightly/pprof2csv/models"
+	"github.com/Lslightly/pprof2csv/srcpath"
 	"github.com/stretchr/testify/assert"
 )
 
-func assertCum(t *testing.T, expectCum time.Duration, sls []*models.SourceLine, fileEndPart string, line int) {
+func assertCum(t *testing.T, expectCum models.Value, sls []*models.SourceLine, fileEndPart string, line int) {
+	found := false
 	for _, sl := range sls {
 		if !strings.HasSuffix(sl.Filename, fileEndPart) {
 			continue
@@ -22,8 +29,10 @@ func assertCum(t *testing.T, expectCum time.Duration, sls []*models.SourceLine,
 		if sl.LineNumber != line {
 			continue
 		}
+		found = true
 		assert.Equal(t, expectCum, sl.Cum, "expected and got cum are not equal")
 	}
+	assert.True(t, found, "no line %s:%d", fileEndPart, line)
 }
 
 var loopOnce sync.Once
@@ -42,7 +51,7 @@ func TestLoop(t *testing.T) {
 	csvFile := common.OpenFile(loopInit())
 	defer csvFile.Close()
 	sls := imexporter.Import(csvFile)
-	assertCum(t, common.ParseDuration("6.05s"), sls, "pprof2csv/testdata/test.go", 69)
+	assertCum(t, models.TimeValue(common.ParseDuration("6.05s")), sls, "test/loop/test.go", 69)
 }
 
 func TestGetTotalProfileTime(t *testing.T) {
@@ -106,3 +115,451 @@ func TestGetCalleeKNameSet_NotFound(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "not found in the profile")
 }
+
+func TestHeapProfile(t *testing.T) {
+	// heap.pprof is written by heap/heap.go with MemProfileRate = 1, default sample type is alloc_space
+	sls, funcStats, err := analyzer.LoadProfileDataWithFunctionStats(filepath.Join(common.CurFileDir(), "heap/heap.pprof"), "")
+	assert.Nil(t, err)
+	assertCum(t, models.Value{Amount: 64000, Unit: models.UnitBytes}, sls, "test/heap/heap.go", 15)
+	// 100 * 64KiB plus two small runtime allocations attributed to the same line
+	assertCum(t, models.Value{Amount: 6553600 + 16 + 112, Unit: models.UnitBytes}, sls, "test/heap/heap.go", 22)
+	assert.Equal(t, models.UnitBytes, funcStats["main.allocLarge"].Cum.Unit)
+}
+
+func TestSampleIndex(t *testing.T) {
+	heapPath := filepath.Join(common.CurFileDir(), "heap/heap.pprof")
+
+	sls, _, err := analyzer.LoadProfileDataWithOptions(heapPath, analyzer.Options{SampleIndex: "alloc_objects"})
+	assert.Nil(t, err)
+	assertCum(t, models.Value{Amount: 1000, Unit: models.UnitCount}, sls, "test/heap/heap.go", 15)
+
+	// index 1 is alloc_space
+	sls, _, err = analyzer.LoadProfileDataWithOptions(heapPath, analyzer.Options{SampleIndex: "1"})
+	assert.Nil(t, err)
+	assertCum(t, models.Value{Amount: 64000, Unit: models.UnitBytes}, sls, "test/heap/heap.go", 15)
+
+	_, _, err = analyzer.LoadProfileDataWithOptions(heapPath, analyzer.Options{SampleIndex: "delay"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "alloc_objects alloc_space inuse_objects inuse_space")
+}
+
+func TestGetTotalProfileValue(t *testing.T) {
+	// samples/count of the CPU profile, 6.17s at 100Hz
+	total, err := analyzer.GetTotalProfileValue([]string{filepath.Join(common.CurFileDir(), "loop/cpu.pprof")}, "samples")
+	assert.Nil(t, err)
+	assert.Equal(t, models.Value{Amount: 617, Unit: models.UnitCount}, total)
+}
+
+func TestHeapCSVRoundTrip(t *testing.T) {
+	sls, _, err := analyzer.LoadProfileDataWithOptions(filepath.Join(common.CurFileDir(), "heap/heap.pprof"), analyzer.Options{SampleIndex: "alloc_objects"})
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, imexporter.New().Export(&buf, sls, ""))
+	imported := imexporter.Import(&buf)
+	assertCum(t, models.Value{Amount: 1000, Unit: models.UnitCount}, imported, "test/heap/heap.go", 15)
+}
+
+func TestAnalyzeContentionSampleTypes(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(common.CurFileDir(), "contention/block.pprof"))
+	assert.Nil(t, err)
+
+	isContention, err := analyzer.IsContentionProfile(data)
+	assert.Nil(t, err)
+	assert.True(t, isContention)
+
+	sampleTypes, lines, err := analyzer.AnalyzeSampleTypes(data, analyzer.Options{}, analyzer.ContentionSampleTypes)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"contentions", "delay"}, sampleTypes)
+
+	// sorted by delay, the default sample type
+	assert.Equal(t, models.TimeValue(1297210694), lines[0].Cum[1])
+	for _, line := range lines {
+		if line.FunctionName == "sync.(*Mutex).Lock" {
+			assert.Equal(t, models.Value{Amount: 399, Unit: models.UnitCount}, line.Flat[0])
+			assert.Equal(t, models.TimeValue(1297210694), line.Flat[1])
+		}
+	}
+
+	// CPU profiles are not contention profiles
+	data, err = os.ReadFile(filepath.Join(common.CurFileDir(), "loop/cpu.pprof"))
+	assert.Nil(t, err)
+	isContention, err = analyzer.IsContentionProfile(data)
+	assert.Nil(t, err)
+	assert.False(t, isContention)
+}
+
+func TestAnalyzeRootFunctions(t *testing.T) {
+	// goroutine.pprof is written by goroutine/goroutine.go with 10 worker, 5 leak and the main goroutine
+	data, err := os.ReadFile(filepath.Join(common.CurFileDir(), "goroutine/goroutine.pprof"))
+	assert.Nil(t, err)
+
+	roots, err := analyzer.AnalyzeRootFunctions(data, analyzer.Options{})
+	assert.Nil(t, err)
+	assert.Len(t, roots, 3)
+	assert.Equal(t, "main.worker", roots[0].FunctionName)
+	assert.Equal(t, 11, roots[0].StartLine)
+	assert.Equal(t, models.Value{Amount: 10, Unit: models.UnitCount}, roots[0].Value)
+	assert.Equal(t, "main.leak", roots[1].FunctionName)
+	assert.Equal(t, models.Value{Amount: 5, Unit: models.UnitCount}, roots[1].Value)
+
+	sls, funcStats, err := analyzer.AnalyzeWithOptions(data, analyzer.Options{})
+	assert.Nil(t, err)
+	assertCum(t, models.Value{Amount: 5, Unit: models.UnitCount}, sls, "test/goroutine/goroutine.go", 18)
+	assert.Equal(t, int64(15), funcStats["runtime.chanrecv1"].Cum.Amount)
+}
+
+func TestAllSampleTypesCSVRoundTrip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(common.CurFileDir(), "heap/heap.pprof"))
+	assert.Nil(t, err)
+
+	sampleTypes, lines, err := analyzer.AnalyzeSampleTypes(data, analyzer.Options{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alloc_objects", "alloc_space", "inuse_objects", "inuse_space"}, sampleTypes)
+
+	var buf bytes.Buffer
+	assert.Nil(t, imexporter.New().ExportMulti(&buf, sampleTypes, lines, ""))
+	importedTypes, imported := imexporter.ImportMulti(&buf)
+	assert.Equal(t, sampleTypes, importedTypes)
+	assert.Len(t, imported, len(lines))
+	for _, line := range imported {
+		if strings.HasSuffix(line.Filename, "test/heap/heap.go") && line.LineNumber == 15 {
+			assert.Equal(t, models.Value{Amount: 1000, Unit: models.UnitCount}, line.Cum[0])
+			assert.Equal(t, models.Value{Amount: 64000, Unit: models.UnitBytes}, line.Cum[1])
+			assert.Equal(t, models.Value{Amount: 0, Unit: models.UnitBytes}, line.Cum[3])
+		}
+	}
+}
+
+func TestAnalyzeAllocSizes(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(common.CurFileDir(), "heap/heap.pprof"))
+	assert.Nil(t, err)
+
+	buckets, err := analyzer.AnalyzeAllocSizes(data, analyzer.Options{})
+	assert.Nil(t, err)
+
+	// line 22 allocates 100 objects of 64KiB plus two small runtime objects
+	classes := make(map[int64]*models.AllocSizeBucket)
+	for _, b := range buckets {
+		if strings.HasSuffix(b.Filename, "test/heap/heap.go") && b.LineNumber == 22 {
+			classes[b.SizeClass] = b
+		}
+	}
+	assert.Len(t, classes, 3)
+	assert.Equal(t, models.Value{Amount: 100, Unit: models.UnitCount}, classes[65536].Objects)
+	assert.Equal(t, models.Value{Amount: 6553600, Unit: models.UnitBytes}, classes[65536].Bytes)
+	assert.Equal(t, models.Value{Amount: 112, Unit: models.UnitBytes}, classes[112].Bytes)
+
+	// CPU profiles have no object sizes
+	data, err = os.ReadFile(filepath.Join(common.CurFileDir(), "loop/cpu.pprof"))
+	assert.Nil(t, err)
+	_, err = analyzer.AnalyzeAllocSizes(data, analyzer.Options{})
+	assert.NotNil(t, err)
+}
+
+func TestMergeProfiles(t *testing.T) {
+	pattern := filepath.Join(common.CurFileDir(), "protoactor-go/BenchmarkPushPop/cpu-*.out")
+	paths, err := filepath.Glob(pattern)
+	assert.Nil(t, err)
+	assert.Len(t, paths, 3)
+
+	const benchFunc = "github.com/asynkron/protoactor-go/internal/queue/mpsc.benchmarkPushPop.func2"
+	var sum, benchSum models.Value
+	for _, path := range paths {
+		total, err := analyzer.GetTotalProfileValue([]string{path}, "")
+		assert.Nil(t, err)
+		sum = sum.Add(total)
+		_, funcStats, err := analyzer.LoadProfileDataWithOptions(path, analyzer.Options{})
+		assert.Nil(t, err)
+		benchSum = benchSum.Add(funcStats[benchFunc].Cum)
+	}
+	merged, err := analyzer.GetTotalProfileValue([]string{pattern}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, sum, merged)
+
+	_, funcStats, err := analyzer.LoadProfilesWithOptions(paths, analyzer.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, benchSum, funcStats[benchFunc].Cum)
+
+	_, _, err = analyzer.LoadProfilesWithOptions([]string{paths[0], filepath.Join(common.CurFileDir(), "heap/heap.pprof")}, analyzer.Options{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "incompatible sample types")
+}
+
+func TestSummarizeProfile(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(common.CurFileDir(), "loop/cpu.pprof"))
+	assert.Nil(t, err)
+	summary, err := analyzer.SummarizeProfile(data, analyzer.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "cpu", summary.SampleType)
+	assert.Equal(t, models.TimeValue(common.ParseDuration("6.17s")), summary.Total)
+	assert.Equal(t, int64(617), summary.Samples)
+	assert.Equal(t, "6.42s", summary.Duration.Round(10*time.Millisecond).String())
+	assert.Equal(t, common.ParseDuration("6.17s"), summary.CPUTime)
+
+	// The samples sample type has the same CPU time, one period per sample
+	summary, err = analyzer.SummarizeProfile(data, analyzer.Options{SampleIndex: "samples"})
+	assert.Nil(t, err)
+	assert.Equal(t, common.ParseDuration("6.17s"), summary.CPUTime)
+}
+
+func TestPercentColumns(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(common.CurFileDir(), "loop/cpu.pprof"))
+	assert.Nil(t, err)
+	opts := analyzer.Options{ShowFrom: "main.busyWork"}
+	_, funcStats, err := analyzer.AnalyzeWithOptions(data, opts)
+	assert.Nil(t, err)
+
+	exporter := imexporter.New()
+	exporter.Totals, err = analyzer.PercentTotals(data, opts, false)
+	assert.Nil(t, err)
+	assert.Equal(t, common.ParseDuration("6.17s"), exporter.Totals.Total.Duration())
+	var buf bytes.Buffer
+	assert.Nil(t, exporter.ExportFunctions(&buf, map[string]*models.FunctionStat{"main.busyWork": funcStats["main.busyWork"]}, ""))
+	// 510ms of 6.17s CPU time in a 6.42s profile
+	assert.Equal(t, "function,flat,cum,unit,flat%,cum%,sum%,flat_cores,cum_cores\nmain.busyWork,510ms,510ms,nanoseconds,8.27,8.27,8.27,0.0795,0.0795\n", buf.String())
+
+	exporter.Totals, err = analyzer.PercentTotals(data, opts, true)
+	assert.Nil(t, err)
+	assert.Equal(t, common.ParseDuration("510ms"), exporter.Totals.Total.Duration())
+	buf.Reset()
+	assert.Nil(t, exporter.ExportFunctions(&buf, funcStats, ""))
+	assert.Contains(t, buf.String(), "main.busyWork,510ms,510ms,nanoseconds,100.00,100.00,100.00,0.0795,0.0795\n")
+
+	// sum% is the running total of flat%, heap profiles have no cores columns
+	heapOpts := analyzer.Options{SampleIndex: "alloc_objects"}
+	sls, _, err := analyzer.LoadProfileDataWithOptions(filepath.Join(common.CurFileDir(), "heap/heap.pprof"), heapOpts)
+	assert.Nil(t, err)
+	heapData, err := os.ReadFile(filepath.Join(common.CurFileDir(), "heap/heap.pprof"))
+	assert.Nil(t, err)
+	exporter.Totals, err = analyzer.PercentTotals(heapData, heapOpts, false)
+	assert.Nil(t, err)
+	buf.Reset()
+	assert.Nil(t, exporter.Export(&buf, sls, ""))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"flat%", "cum%", "sum%"}, rows[0][8:])
+	assert.Equal(t, "100.00", rows[len(rows)-1][10])
+
+	// Import ignores the percentage columns
+	buf.Reset()
+	assert.Nil(t, exporter.Export(&buf, sls, ""))
+	assert.Equal(t, sls, imexporter.Import(&buf))
+
+	// Contention profiles get percentage columns for each sample type
+	blockData, err := os.ReadFile(filepath.Join(common.CurFileDir(), "contention/block.pprof"))
+	assert.Nil(t, err)
+	sampleTypes, multiLines, err := analyzer.AnalyzeSampleTypes(blockData, analyzer.Options{}, analyzer.ContentionSampleTypes)
+	assert.Nil(t, err)
+	multiExporter := imexporter.New()
+	multiExporter.MultiTotals, err = analyzer.SampleTypeTotals(blockData, analyzer.Options{}, false, sampleTypes)
+	assert.Nil(t, err)
+	buf.Reset()
+	assert.Nil(t, multiExporter.ExportMulti(&buf, sampleTypes, multiLines, ""))
+	multiCSV := buf.String()
+	rows, err = csv.NewReader(&buf).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"inlined", "inlined_into", "flat%_contentions", "cum%_contentions", "sum%_contentions", "flat%_delay", "cum%_delay", "sum%_delay"}, rows[0][7:])
+	assert.Equal(t, "100.00", rows[len(rows)-1][11])
+	assert.Equal(t, "100.00", rows[len(rows)-1][14])
+
+	// ImportMulti ignores them
+	importedTypes, imported := imexporter.ImportMulti(strings.NewReader(multiCSV))
+	assert.Equal(t, sampleTypes, importedTypes)
+	assert.Equal(t, multiLines[0].Cum, imported[0].Cum)
+	assert.Equal(t, multiLines[0].InlinedInto, imported[0].InlinedInto)
+}
+
+func TestConvertDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "protoactor-go")
+	common.RuncmdCheck(common.RootDir(), "cp", "-r", filepath.Join(common.CurFileDir(), "protoactor-go"), dir)
+	common.RuncmdCheck(common.RootDir(), "go", "run", ".", "-i", dir)
+
+	indexFile := common.OpenFile(filepath.Join(dir, "index.csv"))
+	defer indexFile.Close()
+	records, err := csv.NewReader(indexFile).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"path", "benchmark", "kind", "setting", "variant", "sample_type", "total", "unit", "samples", "duration"}, records[0])
+	assert.Len(t, records, 10)
+	assert.Equal(t, []string{"BenchmarkPushPop/cpu-off-default.out", "BenchmarkPushPop", "cpu", "off", "default", "cpu", "29.62s", "nanoseconds", "2962", "5.70346567s"}, records[8])
+
+	csvFile := common.OpenFile(filepath.Join(dir, "BenchmarkPushPop/cpu-off-default.csv"))
+	defer csvFile.Close()
+	assert.NotEmpty(t, imexporter.Import(csvFile))
+
+	// Filters apply to the CSVs, the index summarizes the whole profiles
+	focusedPath := filepath.Join(dir, "focused.csv")
+	common.RuncmdCheck(common.RootDir(), "go", "run", ".", "-i", dir, "-focus", "runtime.mallocgc", "-o", focusedPath)
+	focusedFile := common.OpenFile(focusedPath)
+	defer focusedFile.Close()
+	focused, err := csv.NewReader(focusedFile).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, records, focused)
+}
+
+func TestNormalizePaths(t *testing.T) {
+	profPath := filepath.Join(common.CurFileDir(), "protoactor-go/BenchmarkPushPop/cpu-100-default.out")
+	const mpsc = "/home/lqw/mygit/benchs/protoactor-go/internal/queue/mpsc/mpsc.go"
+
+	sls, _, err := analyzer.LoadProfileDataWithOptions(profPath, analyzer.Options{})
+	assert.Nil(t, err)
+	filenames := make(map[string]bool)
+	for _, sl := range sls {
+		filenames[sl.Filename] = true
+		assert.False(t, strings.HasPrefix(sl.Filename, "/home/lqw/mygit/go1.24.2/"), sl.Filename)
+	}
+	assert.True(t, filenames["$GOROOT/src/runtime/malloc.go"])
+	assert.True(t, filenames[mpsc])
+
+	paths := srcpath.Normalizer{Remaps: []srcpath.Remap{{Old: "/home/lqw/mygit/benchs/protoactor-go/", New: "github.com/asynkron/protoactor-go/"}}}
+	sls, _, err = analyzer.LoadProfileDataWithOptions(profPath, analyzer.Options{Paths: paths})
+	assert.Nil(t, err)
+	filenames = make(map[string]bool)
+	for _, sl := range sls {
+		filenames[sl.Filename] = true
+	}
+	assert.True(t, filenames["github.com/asynkron/protoactor-go/internal/queue/mpsc/mpsc.go"])
+	assert.False(t, filenames[mpsc])
+
+	sls, _, err = analyzer.LoadProfileDataWithOptions(profPath, analyzer.Options{Paths: srcpath.Normalizer{Raw: true}})
+	assert.Nil(t, err)
+	assertCum(t, models.TimeValue(common.ParseDuration("2.86s")), sls, "/home/lqw/mygit/go1.24.2/src/runtime/malloc.go", 1399)
+}
+
+func TestRecursiveCumGoParser(t *testing.T) {
+	profPath := filepath.Join(common.CurFileDir(), "go_parser/default.out")
+	total, err := analyzer.GetTotalProfileValue([]string{profPath}, "")
+	assert.Nil(t, err)
+
+	lines, funcStats, err := analyzer.LoadProfileDataWithOptions(profPath, analyzer.Options{})
+	assert.Nil(t, err)
+	for _, line := range lines {
+		assert.LessOrEqual(t, line.Cum.Amount, total.Amount, line.FunctionName)
+	}
+	for _, fs := range funcStats {
+		assert.LessOrEqual(t, fs.Cum.Amount, total.Amount, fs.FunctionName)
+	}
+	assert.Equal(t, models.TimeValue(common.ParseDuration("32.43s")), funcStats["go/ast.Walk"].Cum)
+
+	// go/ast.Walk is recursive, counting every frame counts its samples about 5 times
+	_, funcStats, err = analyzer.LoadProfileDataWithOptions(profPath, analyzer.Options{PerFrameCum: true})
+	assert.Nil(t, err)
+	assert.Equal(t, models.TimeValue(common.ParseDuration("174.51s")), funcStats["go/ast.Walk"].Cum)
+}
+
+func TestAnalyzeAddresses(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(common.CurFileDir(), "loop/cpu.pprof"))
+	assert.Nil(t, err)
+	stats, err := analyzer.AnalyzeAddresses(data, analyzer.Options{})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, stats)
+
+	var flat models.Value
+	for i, as := range stats {
+		flat = flat.Add(as.Flat)
+		assert.NotEmpty(t, as.FunctionName)
+		assert.NotEmpty(t, as.MappingFile)
+		assert.LessOrEqual(t, as.Flat.Amount, as.Cum.Amount)
+		if i > 0 {
+			assert.GreaterOrEqual(t, stats[i-1].Flat.Amount, as.Flat.Amount)
+		}
+	}
+	assert.Equal(t, 6170*time.Millisecond, flat.Duration())
+	assert.Equal(t, "main.benchmarkFunction", stats[0].FunctionName)
+	assert.Equal(t, 29, stats[0].LineNumber)
+	assert.Equal(t, 2940*time.Millisecond, stats[0].Flat.Duration())
+
+	var buf bytes.Buffer
+	assert.Nil(t, imexporter.New().ExportAddresses(&buf, stats, "", false))
+	assert.True(t, strings.HasPrefix(buf.String(), "address,mapping,offset,function,file,line,flat,cum,unit\n0x4bd154,"))
+}
+
+func TestCallGraph(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(common.CurFileDir(), "loop/cpu.pprof"))
+	assert.Nil(t, err)
+	graph, err := analyzer.BuildCallGraph(data, analyzer.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, common.ParseDuration("6.17s"), graph.Total.Duration())
+
+	// Nodes have the function stats of AnalyzeWithOptions
+	_, funcStats, err := analyzer.AnalyzeWithOptions(data, analyzer.Options{})
+	assert.Nil(t, err)
+	assert.Len(t, graph.Nodes, len(funcStats))
+	for i, node := range graph.Nodes {
+		assert.Equal(t, i+1, node.ID)
+		assert.Equal(t, funcStats[node.FunctionName].Cum, node.Cum)
+		assert.Equal(t, funcStats[node.FunctionName].Flat, node.Flat)
+	}
+	var busyWork *models.GraphEdge
+	for _, e := range graph.Edges {
+		if e.Caller.FunctionName == "main.benchmarkFunction" && e.Callee.FunctionName == "main.busyWork" {
+			busyWork = e
+		}
+	}
+	assert.NotNil(t, busyWork)
+	assert.True(t, busyWork.Inlined)
+	assert.Equal(t, common.ParseDuration("420ms"), busyWork.Weight.Duration())
+
+	// 1% of 6.17s keeps the nodes and edges of at least 61.7ms
+	analyzer.PruneCallGraph(graph, 0.01, 0.01)
+	var names []string
+	for _, node := range graph.Nodes {
+		names = append(names, node.FunctionName)
+	}
+	assert.Equal(t, []string{"main.main", "runtime.main", "main.benchmarkFunction", "main.busyWork"}, names)
+	assert.Len(t, graph.Edges, 3)
+	for _, e := range graph.Edges {
+		assert.GreaterOrEqual(t, e.Weight.Amount, int64(61700000))
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, imexporter.ExportDOT(&buf, graph, ""))
+	assert.True(t, strings.HasPrefix(buf.String(), "digraph \"callgraph\" {\n"))
+	assert.Contains(t, buf.String(), "N3 [label=\"main.benchmarkFunction\\n5.59s (90.60%)\\nof 6.05s (98.06%)\"")
+	assert.Contains(t, buf.String(), "N3 -> N4 [label=\" 420ms (inline)\" penwidth=1.34 style=dashed]\n")
+
+	buf.Reset()
+	assert.Nil(t, imexporter.ExportGraphJSON(&buf, graph))
+	var doc struct {
+		Unit  string
+		Total int64
+		Nodes []struct {
+			ID       int
+			Function string
+			Cum      int64
+		}
+		Edges []struct {
+			Source, Target int
+			Weight         int64
+			Inlined        bool
+		}
+	}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "nanoseconds", doc.Unit)
+	assert.Equal(t, int64(6170000000), doc.Total)
+	assert.Len(t, doc.Nodes, 4)
+	assert.Equal(t, "main.benchmarkFunction", doc.Nodes[2].Function)
+	assert.Contains(t, doc.Edges, struct {
+		Source, Target int
+		Weight         int64
+		Inlined        bool
+	}{3, 4, 420000000, true})
+
+	buf.Reset()
+	assert.Nil(t, imexporter.ExportGraphML(&buf, graph))
+	var graphML struct {
+		Graph struct {
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	assert.Nil(t, xml.Unmarshal(buf.Bytes(), &graphML))
+	assert.Len(t, graphML.Graph.Nodes, 4)
+	assert.Equal(t, "n2", graphML.Graph.Edges[0].Source)
+	assert.Equal(t, "n1", graphML.Graph.Edges[0].Target)
+}
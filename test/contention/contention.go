@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+var (
+	mu      sync.Mutex
+	counter int
+)
+
+// holdLock keeps the mutex for a while so that other goroutines block on it
+func holdLock() {
+	mu.Lock()
+	time.Sleep(time.Millisecond)
+	counter++
+	mu.Unlock()
+}
+
+// contend runs 100 holdLock calls in each of 4 goroutines
+func contend() {
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				holdLock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func writeProfile(name, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("could not create %s profile: %v", name, err)
+	}
+	defer f.Close()
+
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		log.Fatalf("could not write %s profile: %v", name, err)
+	}
+}
+
+func main() {
+	// Record every blocking event and every mutex contention
+	runtime.SetBlockProfileRate(1)
+	runtime.SetMutexProfileFraction(1)
+
+	contend()
+
+	writeProfile("block", "block.pprof")
+	writeProfile("mutex", "mutex.pprof")
+}
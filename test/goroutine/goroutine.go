@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime/pprof"
+	"sync"
+)
+
+// worker blocks until done is closed
+func worker(done chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	<-done
+}
+
+// leak blocks forever on a channel nobody sends to
+func leak(ch chan int) {
+	<-ch
+}
+
+func main() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// 10 goroutines started from worker and 5 from leak
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go worker(done, &wg)
+	}
+	for i := 0; i < 5; i++ {
+		go leak(make(chan int))
+	}
+
+	f, err := os.Create("goroutine.pprof")
+	if err != nil {
+		log.Fatal("could not create goroutine profile: ", err)
+	}
+	defer f.Close()
+
+	if err := pprof.Lookup("goroutine").WriteTo(f, 0); err != nil {
+		log.Fatal("could not write goroutine profile: ", err)
+	}
+
+	close(done)
+	wg.Wait()
+}
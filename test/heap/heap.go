@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+var sink []byte
+
+// allocSmall allocates 1000 objects of 64 bytes
+func allocSmall() {
+	for i := 0; i < 1000; i++ {
+		sink = make([]byte, 64)
+	}
+}
+
+// allocLarge allocates 100 objects of 64KiB
+func allocLarge() {
+	for i := 0; i < 100; i++ {
+		sink = make([]byte, 64<<10)
+	}
+}
+
+func main() {
+	// Record every allocation so that the expected values are exact
+	runtime.MemProfileRate = 1
+
+	allocSmall()
+	allocLarge()
+	sink = nil
+
+	// Heap profile is published at the end of a GC cycle
+	runtime.GC()
+
+	f, err := os.Create("heap.pprof")
+	if err != nil {
+		log.Fatal("could not create heap profile: ", err)
+	}
+	defer f.Close()
+
+	if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+		log.Fatal("could not write heap profile: ", err)
+	}
+}